@@ -1,17 +1,35 @@
 package main
 
 import (
+	"context"
 	"flag"
 	"fmt"
 	"log"
 	"os"
+	"os/signal"
+	"path/filepath"
 	"regexp"
+	"slices"
+	"strconv"
 	"strings"
 	"sync"
 
 	"github.com/fatih/color"
+	"github.com/kachick/selfup/internal/config"
+	"github.com/kachick/selfup/internal/filter"
+	"github.com/kachick/selfup/internal/git"
+	"github.com/kachick/selfup/internal/interactive"
+	"github.com/kachick/selfup/internal/lock"
+	"github.com/kachick/selfup/internal/lsp"
 	"github.com/kachick/selfup/internal/migrate"
+	"github.com/kachick/selfup/internal/policy"
+	"github.com/kachick/selfup/internal/report"
 	"github.com/kachick/selfup/internal/runner"
+	"github.com/kachick/selfup/internal/schema"
+	"github.com/kachick/selfup/internal/structured"
+	"github.com/kachick/selfup/internal/syntax"
+	"github.com/kachick/selfup/internal/trust"
+	"github.com/kachick/selfup/internal/watch"
 	"golang.org/x/term"
 	"golang.org/x/xerrors"
 )
@@ -21,25 +39,203 @@ var (
 	version = "dev"
 )
 
-type Result struct {
-	Path       string
-	FileResult runner.Result
-	Err        error
+func printTargets(files []report.File, isColor bool) {
+	for _, r := range files {
+		for _, t := range r.Result.Targets {
+			estimation := " "
+			suffix := ""
+			replacer := t.Replacer
+			if t.IsChanged {
+				estimation = "✓"
+				if isColor {
+					green := color.New(color.FgGreen).SprintFunc()
+					estimation = green(estimation)
+					replacer = green(t.Replacer)
+				}
+				suffix = fmt.Sprintf(" => %s", replacer)
+			}
+			fmt.Printf("%s %s:%d:%d: %s%s\n", estimation, r.Path, t.LineNumber, t.ValueRunes.Start+1, t.Extracted, suffix)
+		}
+		for _, s := range r.Result.Skipped {
+			fmt.Printf("- %s:%d: skipped by %s\n", r.Path, s.LineNumber, s.Directive)
+		}
+	}
+}
+
+// Collects values of the repeated flag
+type stringsFlag []string
+
+func (f *stringsFlag) String() string {
+	return strings.Join(*f, ",")
+}
+
+func (f *stringsFlag) Set(value string) error {
+	*f = append(*f, value)
+	return nil
+}
+
+// Returns the nearest ancestor that has .git, or the working directory if not found
+func findRoot(dir string) string {
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return ""
+	}
+	for current := abs; ; current = filepath.Dir(current) {
+		if _, err := os.Stat(filepath.Join(current, ".git")); err == nil {
+			return current
+		}
+		if current == filepath.Dir(current) {
+			break
+		}
+	}
+	wd, err := os.Getwd()
+	if err != nil {
+		return ""
+	}
+
+	return wd
+}
+
+// Combines the patterns into one regex, returns nil for no patterns
+func union(patterns []string) (*regexp.Regexp, error) {
+	if len(patterns) == 0 {
+		return nil, nil
+	}
+	groups := make([]string, 0, len(patterns))
+	for _, p := range patterns {
+		groups = append(groups, "(?:"+p+")")
+	}
+
+	return regexp.Compile(strings.Join(groups, "|"))
+}
+
+// Resolves the prefix and suffix for each file from the flags and the config
+type markers struct {
+	prefixes []string
+	suffixes []string
+	bound    []config.Marker
+
+	mu    sync.Mutex
+	cache map[string][2]*regexp.Regexp
+}
+
+func (m *markers) validate() error {
+	for _, p := range slices.Concat(m.prefixes, m.suffixes) {
+		_, err := regexp.Compile(p)
+		if err != nil {
+			return xerrors.Errorf("Given an invalid regex: `%w`", err)
+		}
+	}
+	for _, b := range m.bound {
+		for _, p := range []string{b.Prefix, b.Suffix} {
+			_, err := regexp.Compile(p)
+			if err != nil {
+				return xerrors.Errorf("Given an invalid regex in the config: `%w`", err)
+			}
+		}
+	}
+
+	return nil
+}
+
+func (m *markers) For(path string) (prefix *regexp.Regexp, suffix *regexp.Regexp) {
+	prefixes := slices.Clone(m.prefixes)
+	suffixes := slices.Clone(m.suffixes)
+	for _, b := range m.bound {
+		if !b.Match(path) {
+			continue
+		}
+		prefixes = append(prefixes, b.Prefix)
+		if b.Suffix != "" {
+			suffixes = append(suffixes, b.Suffix)
+		}
+	}
+	key := strings.Join(prefixes, "\x00") + "\x01" + strings.Join(suffixes, "\x00")
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if cached, ok := m.cache[key]; ok {
+		return cached[0], cached[1]
+	}
+	// Patterns are checked in validate
+	prefix, _ = union(prefixes)
+	suffix, _ = union(suffixes)
+	if m.cache == nil {
+		m.cache = map[string][2]*regexp.Regexp{}
+	}
+	m.cache[key] = [2]*regexp.Regexp{prefix, suffix}
+
+	return prefix, suffix
+}
+
+func scanFile(path string, prefix *regexp.Regexp, suffix *regexp.Regexp, language *syntax.Language, skipBy string, allowUnknownFields bool) ([]runner.Annotation, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	return runner.Scan(file, prefix, suffix, language, skipBy, allowUnknownFields)
 }
 
 func main() {
 	versionFlag := flag.Bool("version", false, "print the version of this program")
 
-	sharedFlags := flag.NewFlagSet("run|list", flag.ExitOnError)
-	prefixFlag := sharedFlags.String("prefix", "\\s*[#;/]* selfup ", "start JSON after this pattern(RE2)")
+	sharedFlags := flag.NewFlagSet("run|list|lock|validate|trust|report|lsp", flag.ExitOnError)
+	var prefixes, suffixes []string
+	sharedFlags.Var((*stringsFlag)(&prefixes), "prefix", "start JSON after this pattern(RE2), repeatable, defaults to "+strconv.Quote(runner.DefaultPrefix))
+	sharedFlags.Var((*stringsFlag)(&suffixes), "suffix", "end JSON before this pattern(RE2) like ` -->`, repeatable")
+	commentsOnlyFlag := sharedFlags.Bool("comments-only", false, "handle only definitions in comments of known languages like YAML and Go")
 	skipByFlag := sharedFlags.String("skip-by", "", "skip to run if the line contains this string")
 	checkFlag := sharedFlags.Bool("check", false, "exit as error if found changes")
 	noColorFlag := sharedFlags.Bool("no-color", false, "disable color output")
+	markdownFlag := sharedFlags.Bool("markdown", false, "alias of --format markdown")
+	formatFlag := sharedFlags.String("format", "", "output format: text, markdown, github, sarif or junit, defaults to markdown in report and text in others")
+	lockedFlag := sharedFlags.Bool("locked", false, "apply values from the lock file without executing commands")
+	lockFileFlag := sharedFlags.String("lock-file", lock.DefaultPath, "path of the lock file")
+	allowUnknownFieldsFlag := sharedFlags.Bool("allow-unknown-fields", false, "ignore unknown fields in definitions")
+	cleanEnvFlag := sharedFlags.Bool("clean-env", false, "pass only allowlisted environment variables to replacers")
+	configFlag := sharedFlags.String("config", config.DefaultPath, "path of the config file")
+	trustFlag := sharedFlags.Bool("trust", false, "execute commands even if they are not allowed by the policy")
+	noExecFlag := sharedFlags.Bool("no-exec", false, "do not execute any command, only built-in resolvers like --locked are allowed")
+	requireTrustFlag := sharedFlags.Bool("require-trust", true, "refuse to run if any command is not approved with `selfup trust`, disable with --require-trust=false")
+	trustStoreFlag := sharedFlags.String("trust-store", trust.DefaultPath(), "path of the approved commands")
+	gitTrackedFlag := sharedFlags.Bool("git-tracked", false, "target files tracked by git, PATHs are used as pathspecs")
+	commitFlag := sharedFlags.Bool("commit", false, "commit the files modified by run")
+	branchFlag := sharedFlags.String("branch", "", "create this branch before committing, implies --commit")
+	commitMessageFlag := sharedFlags.String("commit-message", report.DefaultCommitMessage, "commit message in Go text/template with .Changes")
+	watchFlag := sharedFlags.Bool("watch", false, "re-run list when the files are modified")
+	interactiveFlag := sharedFlags.Bool("interactive", false, "ask which changes should be applied in run")
+	changedSinceFlag := sharedFlags.String("changed-since", "", "target files changed since this git ref, PATHs are used as pathspecs")
+	selection := filter.Filter{}
+	sharedFlags.Var((*stringsFlag)(&selection.OnlyFiles), "only-file", "handle only files matching this path or glob, repeatable")
+	sharedFlags.Var((*stringsFlag)(&selection.OnlyLines), "only-line", "handle only this `path:N`, repeatable")
+	sharedFlags.Var((*stringsFlag)(&selection.OnlyCommands), "only-command", "handle only this executable or whole command, repeatable")
+	sharedFlags.Var((*stringsFlag)(&selection.OnlyIDs), "only-id", "handle only definitions with this id, repeatable")
+	sharedFlags.Var((*stringsFlag)(&selection.ExcludeFiles), "exclude-file", "skip files matching this path or glob, repeatable")
+	sharedFlags.Var((*stringsFlag)(&selection.ExcludeLines), "exclude-line", "skip this `path:N`, repeatable")
+	sharedFlags.Var((*stringsFlag)(&selection.ExcludeCommands), "exclude-command", "skip this executable or whole command, repeatable")
+	sharedFlags.Var((*stringsFlag)(&selection.ExcludeIDs), "exclude-id", "skip definitions with this id, repeatable")
 
 	const usage = `Usage: selfup [SUB] [OPTIONS] [PATH]...
 
 $ selfup run .github/workflows/*.yml
 $ selfup list --check .github/workflows/*.yml
+$ selfup list --watch .github/workflows/*.yml
+$ selfup list --check --git-tracked --changed-since HEAD
+$ selfup lock .github/workflows/*.yml
+$ selfup run --locked .github/workflows/*.yml
+$ selfup run --only-command dprint .github/workflows/*.yml
+$ selfup run --interactive .github/workflows/*.yml
+$ selfup run --branch selfup-update .github/workflows/*.yml
+$ selfup report --markdown .github/workflows/*.yml | gh pr create --body-file -
+$ selfup list --check --format github .github/workflows/*.yml
+$ selfup list --format sarif .github/workflows/*.yml > selfup.sarif
+$ selfup list --check --format junit .github/workflows/*.yml > selfup.xml
+$ selfup validate .github/workflows/*.yml
+$ selfup trust .github/workflows/*.yml
+$ selfup schema
+$ selfup lsp
 `
 
 	flag.Usage = func() {
@@ -51,11 +247,9 @@ $ selfup list --check .github/workflows/*.yml
 		sharedFlags.Usage()
 	}
 
-	version := fmt.Sprintf("%s %s", "selfup", version)
-
 	flag.Parse()
 	if *versionFlag {
-		fmt.Println(version)
+		fmt.Printf("%s %s\n", "selfup", version)
 		return
 	}
 
@@ -67,7 +261,13 @@ $ selfup list --check .github/workflows/*.yml
 	subCommand := os.Args[1]
 	isListMode := subCommand == "list"
 	isRunMode := subCommand == "run"
+	isLockMode := subCommand == "lock"
+	isValidateMode := subCommand == "validate"
+	isTrustMode := subCommand == "trust"
+	isReportMode := subCommand == "report"
+	isLSPMode := subCommand == "lsp"
 	isMigrateMode := subCommand == "migrate"
+	isSchemaMode := subCommand == "schema"
 	if isMigrateMode {
 		paths := os.Args[2:]
 		for _, path := range paths {
@@ -76,111 +276,480 @@ $ selfup list --check .github/workflows/*.yml
 				log.Fatalf("%+v", err)
 			}
 			if isMigrated {
-				log.Println(path + ": migrated schema beta -> v1")
+				log.Println(path + ": migrated schema beta -> " + migrate.Version)
 			}
 		}
 
 		return
 	}
 
-	if !(isListMode || isRunMode) {
+	if isSchemaMode {
+		bytes, err := schema.JSON()
+		if err != nil {
+			log.Fatalf("%+v", err)
+		}
+		os.Stdout.Write(bytes)
+
+		return
+	}
+
+	if !(isListMode || isRunMode || isLockMode || isValidateMode || isTrustMode || isReportMode || isLSPMode) {
 		flag.Usage()
 		log.Fatalf("Specified unexpected subcommand `%s`", subCommand)
 	}
 
 	sharedFlags.Parse(os.Args[2:])
 	paths := sharedFlags.Args()
-	prefixStr := *prefixFlag
+	if *gitTrackedFlag || *changedSinceFlag != "" {
+		repo := git.Repository{}
+		var err error
+		if *changedSinceFlag != "" {
+			paths, err = repo.ChangedFiles(*changedSinceFlag, paths...)
+		} else {
+			paths, err = repo.TrackedFiles(paths...)
+		}
+		if err != nil {
+			log.Fatalf("%+v", err)
+		}
+	}
+	err := selection.Validate()
+	if err != nil {
+		log.Fatalf("%+v", err)
+	}
+	paths = slices.DeleteFunc(paths, func(path string) bool {
+		return !selection.File(path)
+	})
 	skipBy := *skipByFlag
 	isCheckMode := *checkFlag
 	isColor := term.IsTerminal(int(os.Stdout.Fd())) && !(*noColorFlag)
+	format := *formatFlag
+	if format == "" {
+		format = "text"
+		if *markdownFlag || isReportMode {
+			format = "markdown"
+		}
+	}
+	if !slices.Contains([]string{"text", "markdown", "github", "sarif", "junit"}, format) {
+		flag.Usage()
+		log.Fatalf("Specified unexpected format `%s`", format)
+	}
+	if isValidateMode && format != "text" && format != "github" {
+		flag.Usage()
+		log.Fatalf("--format %s is not available in validate, use text or github", format)
+	}
+	if *watchFlag && !isListMode {
+		flag.Usage()
+		log.Fatalf("--watch is available only in list")
+	}
 
-	if prefixStr == "" {
+	if len(prefixes) == 0 {
+		prefixes = []string{runner.DefaultPrefix}
+	}
+	if slices.Contains(prefixes, "") {
 		flag.Usage()
 		log.Fatalf("%+v", xerrors.New("No prefix is specified"))
 	}
-
-	prefix, err := regexp.Compile(prefixStr)
+	cfg, err := config.Load(*configFlag)
+	if err != nil {
+		log.Fatalf("%+v", err)
+	}
+	marks := &markers{prefixes: prefixes, suffixes: slices.DeleteFunc(suffixes, func(s string) bool { return s == "" }), bound: cfg.Markers}
+	err = marks.validate()
 	if err != nil {
-		log.Fatalf("Given an invalid regex: `%v`", err)
+		log.Fatalf("%v", err)
+	}
+	// Unknown file types are handled without the lexer
+	languageOf := func(path string) *syntax.Language {
+		if !*commentsOnlyFlag {
+			return nil
+		}
+		return syntax.ForPath(path)
 	}
 
-	wg := new(sync.WaitGroup)
-	results := make(chan Result, len(paths))
-	for _, path := range paths {
-		wg.Go(func() {
-			fileResult, err := func() (runner.Result, error) {
+	if isValidateMode {
+		hasProblem := false
+		for _, path := range paths {
+			problems, err := func() ([]runner.Problem, error) {
 				file, err := os.Open(path)
 				if err != nil {
-					return runner.Result{}, err
+					return nil, err
 				}
 				defer file.Close()
+				prefix, suffix := marks.For(path)
 
-				return runner.DryRun(file, prefix, skipBy)
+				return runner.ValidateWith(file, runner.Options{
+					Prefix:             prefix,
+					Suffix:             suffix,
+					Language:           languageOf(path),
+					Format:             structured.ForPath(path),
+					SkipBy:             skipBy,
+					AllowUnknownFields: *allowUnknownFieldsFlag,
+					Dir:                filepath.Dir(path),
+					Root:               findRoot(filepath.Dir(path)),
+				})
 			}()
+			if err != nil {
+				log.Fatalf("%s: %+v", path, err)
+			}
+			for _, p := range problems {
+				hasProblem = true
+				if format == "github" {
+					fmt.Print(report.GitHubProblem(path, p))
+					continue
+				}
+				fmt.Printf("%s:%s\n", path, p)
+			}
+		}
+		if hasProblem {
+			os.Exit(1)
+		}
+
+		return
+	}
 
+	// Commands are not executed with --locked and --no-exec
+	var store *trust.Store
+	if isTrustMode || (*requireTrustFlag && !*lockedFlag && !*noExecFlag) {
+		store, err = trust.Load(*trustStoreFlag)
+		if err != nil {
+			log.Fatalf("%+v", err)
+		}
+		untrusted := 0
+		for _, path := range paths {
+			prefix, suffix := marks.For(path)
+			annotations, err := scanFile(path, prefix, suffix, languageOf(path), skipBy, *allowUnknownFieldsFlag)
 			if err != nil {
-				results <- Result{
-					Path: path,
-					Err:  err,
+				log.Fatalf("%s: %+v", path, err)
+			}
+			selected := selection.Definitions(path)
+			for _, a := range annotations {
+				if !selected(a.LineNumber, a.Definition) {
+					continue
+				}
+				source := fmt.Sprintf("%s:%d", path, a.LineNumber)
+				if isTrustMode {
+					if store.Approve(a.Definition, source) {
+						fmt.Printf("+ %s: %s\n", source, strings.Join(a.Definition.Command, " "))
+					}
+					continue
+				}
+				if !store.IsTrusted(a.Definition) {
+					untrusted++
+					log.Printf("%s: `%s` is not trusted", source, strings.Join(a.Definition.Command, " "))
 				}
-				return
 			}
+		}
+		if isTrustMode {
+			err = store.Save(*trustStoreFlag)
+			if err != nil {
+				log.Fatalf("%+v", err)
+			}
+			return
+		}
+		if untrusted > 0 {
+			log.Fatalf("Found %d untrusted commands, review and approve them with `selfup trust`", untrusted)
+		}
+	}
+
+	var executor runner.Executor = runner.ExecExecutor{}
+	if cfg.Policy != nil {
+		guard := &policy.Executor{Next: executor, Policy: *cfg.Policy, Trust: *trustFlag}
+		// STDIN is used for the protocol in LSP
+		if term.IsTerminal(int(os.Stdin.Fd())) && !isLSPMode {
+			guard.Prompt = policy.TerminalPrompt(os.Stdin, os.Stderr)
+		}
+		executor = guard
+	}
+	if *noExecFlag {
+		executor = policy.NoExecExecutor{}
+	}
+
+	var resolver runner.Resolver = runner.CommandResolver{Executor: executor}
+	if *lockedFlag {
+		locked, err := lock.Load(*lockFileFlag)
+		if err != nil {
+			log.Fatalf("%+v", err)
+		}
+		resolver = lock.Resolver{Lock: locked, Root: findRoot(".")}
+	}
+	if store != nil {
+		// Documents opened in LSP and files modified while watching are not in the scan above
+		resolver = trust.Resolver{Next: resolver, Store: store}
+	}
+	if *watchFlag || isLSPMode {
+		resolver = &watch.CachingResolver{Next: resolver}
+	}
+	var recorder *lock.Recorder
+	if isLockMode {
+		recorder = lock.NewRecorder(resolver, findRoot("."))
+		resolver = recorder
+	}
+
+	if isLSPMode {
+		// Used for documents that are not files
+		prefix, suffix := marks.For("")
+		server := &lsp.Server{
+			Options: runner.Options{
+				Prefix:             prefix,
+				Suffix:             suffix,
+				SkipBy:             skipBy,
+				Resolver:           resolver,
+				AllowUnknownFields: *allowUnknownFieldsFlag,
+				Root:               findRoot("."),
+				CleanEnv:           *cleanEnvFlag,
+			},
+			Markers:      marks.For,
+			CommentsOnly: *commentsOnlyFlag,
+			Version:      version,
+		}
+		err := server.Serve(os.Stdin, os.Stdout)
+		if err != nil {
+			log.Fatalf("%+v", err)
+		}
+
+		return
+	}
 
-			isDirty := fileResult.ChangedCount > 0
+	// Handles the files concurrently and returns the results sorted by paths
+	process := func(paths []string) []report.File {
+		wg := new(sync.WaitGroup)
+		results := make(chan report.File, len(paths))
+		for _, path := range paths {
+			wg.Go(func() {
+				fileResult, err := func() (runner.Result, error) {
+					file, err := os.Open(path)
+					if err != nil {
+						return runner.Result{}, err
+					}
+					defer file.Close()
+
+					prefix, suffix := marks.For(path)
+					opts := runner.Options{
+						Prefix:             prefix,
+						Suffix:             suffix,
+						Language:           languageOf(path),
+						Format:             structured.ForPath(path),
+						SkipBy:             skipBy,
+						Resolver:           resolver,
+						AllowUnknownFields: *allowUnknownFieldsFlag,
+						Dir:                filepath.Dir(path),
+						Root:               findRoot(filepath.Dir(path)),
+						CleanEnv:           *cleanEnvFlag,
+					}
+					if !selection.IsEmpty() {
+						opts.Filter = selection.Definitions(path)
+					}
+
+					return runner.DryRunWith(file, opts)
+				}()
 
-			if isRunMode && isDirty {
-				err := os.WriteFile(path, []byte(strings.Join(fileResult.NewLines, "\n")+"\n"), os.ModePerm)
 				if err != nil {
-					results <- Result{
+					results <- report.File{
 						Path: path,
 						Err:  err,
 					}
 					return
 				}
-			}
 
-			results <- Result{
-				Path:       path,
-				FileResult: fileResult,
+				isDirty := fileResult.ChangedCount > 0
+
+				// Interactive mode writes after the answers
+				if isRunMode && isDirty && !*interactiveFlag {
+					err := os.WriteFile(path, []byte(strings.Join(fileResult.NewLines, "\n")+"\n"), os.ModePerm)
+					if err != nil {
+						results <- report.File{
+							Path: path,
+							Err:  err,
+						}
+						return
+					}
+				}
+
+				results <- report.File{
+					Path:   path,
+					Result: fileResult,
+				}
+			})
+		}
+		wg.Wait()
+		close(results)
+		files := []report.File{}
+		for r := range results {
+			files = append(files, r)
+		}
+		slices.SortFunc(files, func(a, b report.File) int {
+			return strings.Compare(a.Path, b.Path)
+		})
+
+		return files
+	}
+	files := process(paths)
+	if *watchFlag {
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+		defer stop()
+		draw := func() {
+			if isColor {
+				// Clears the screen
+				fmt.Print("\033[H\033[2J")
 			}
+			for _, r := range files {
+				if r.Err != nil {
+					fmt.Printf("! %s: %v\n", r.Path, r.Err)
+				}
+			}
+			printTargets(files, isColor)
+			fmt.Printf("\nWatching %d files, press Ctrl+C to stop\n", len(files))
+		}
+		draw()
+		err := watch.Watch(ctx, paths, watch.DefaultDebounce, func(modified []string) {
+			for _, updated := range process(modified) {
+				i := slices.IndexFunc(files, func(f report.File) bool {
+					return f.Path == updated.Path
+				})
+				files[i] = updated
+			}
+			draw()
 		})
+		if err != nil {
+			log.Fatalf("%+v", err)
+		}
+
+		return
+	}
+	if isRunMode && *interactiveFlag {
+		session := interactive.NewSession(os.Stdin, os.Stderr)
+		for i, f := range files {
+			if f.Err != nil || f.Result.ChangedCount == 0 {
+				continue
+			}
+			reviewed, err := session.Review(f.Path, f.Result)
+			if err != nil {
+				log.Fatalf("%+v", err)
+			}
+			files[i].Result = reviewed
+			if reviewed.ChangedCount == 0 {
+				continue
+			}
+			err = os.WriteFile(f.Path, []byte(strings.Join(reviewed.NewLines, "\n")+"\n"), os.ModePerm)
+			if err != nil {
+				files[i].Err = err
+			}
+		}
 	}
-	wg.Wait()
-	close(results)
 	total := 0
 	changed := 0
+	skipped := 0
 	hasError := false
-	for r := range results {
+	for _, r := range files {
 		if r.Err != nil {
-			log.Printf("%s: %+v", r.Path, r.Err)
 			hasError = true
+			// Annotations include the errors
+			if format == "github" || format == "sarif" || format == "junit" {
+				continue
+			}
+			log.Printf("%s: %+v", r.Path, r.Err)
+			var cmdErr *runner.CommandError
+			if xerrors.As(r.Err, &cmdErr) && cmdErr.Stderr != "" {
+				log.Printf("%s: STDERR of `%s`:\n%s", r.Path, strings.Join(cmdErr.Argv, " "), cmdErr.Stderr)
+			}
 			continue
 		}
-		fr := r.FileResult
-		total += fr.Total
-		changed += fr.ChangedCount
-		for _, t := range fr.Targets {
-			estimation := " "
-			suffix := ""
-			replacer := t.Replacer
-			if t.IsChanged {
-				estimation = "✓"
-				if isColor {
-					green := color.New(color.FgGreen).SprintFunc()
-					estimation = green(estimation)
-					replacer = green(t.Replacer)
-				}
-				suffix = fmt.Sprintf(" => %s", replacer)
+		total += r.Result.Total
+		changed += r.Result.ChangedCount
+		skipped += len(r.Result.Skipped)
+	}
+
+	// Text output is shown in the terminal, and Markdown is piped into other tools
+	summary := os.Stdout
+	switch format {
+	case "markdown":
+		fmt.Print(report.Markdown(files))
+		summary = os.Stderr
+	case "sarif":
+		bytes, err := report.SARIF(files, version)
+		if err != nil {
+			log.Fatalf("%+v", err)
+		}
+		os.Stdout.Write(bytes)
+		summary = os.Stderr
+	case "junit":
+		bytes, err := report.JUnit(files, isCheckMode)
+		if err != nil {
+			log.Fatalf("%+v", err)
+		}
+		os.Stdout.Write(bytes)
+		summary = os.Stderr
+	case "github":
+		fmt.Print(report.GitHub(files, isRunMode))
+		if path := os.Getenv("GITHUB_STEP_SUMMARY"); path != "" {
+			err := report.WriteStepSummary(path, files)
+			if err != nil {
+				log.Fatalf("%+v", err)
+			}
+		}
+	default:
+		printTargets(files, isColor)
+	}
+	fmt.Fprintln(summary)
+	switch {
+	case isListMode || isReportMode:
+		fmt.Fprintf(summary, "%d/%d items will be replaced\n", changed, total)
+	case isRunMode:
+		fmt.Fprintf(summary, "%d/%d items have been replaced\n", changed, total)
+		if !(*commitFlag || *branchFlag != "") || hasError || changed == 0 {
+			break
+		}
+		message, err := report.CommitMessage(*commitMessageFlag, files)
+		if err != nil {
+			log.Fatalf("%+v", err)
+		}
+		modified := []string{}
+		for _, f := range files {
+			if f.Result.ChangedCount > 0 {
+				modified = append(modified, f.Path)
+			}
+		}
+		repo := git.Repository{}
+		if *branchFlag != "" {
+			err = repo.CreateBranch(*branchFlag)
+			if err != nil {
+				log.Fatalf("%+v", err)
+			}
+		}
+		err = repo.Add(modified...)
+		if err != nil {
+			log.Fatalf("%+v", err)
+		}
+		err = repo.Commit(message, modified...)
+		if err != nil {
+			log.Fatalf("%+v", err)
+		}
+		fmt.Fprintf(summary, "%d files have been committed\n", len(modified))
+	case isLockMode:
+		if hasError {
+			break
+		}
+		locked := recorder.Lock()
+		// Filtered runs update only the selected entries in the existing lock file
+		if !selection.IsEmpty() || *changedSinceFlag != "" {
+			existing, err := lock.Load(*lockFileFlag)
+			if err != nil && !os.IsNotExist(err) {
+				log.Fatalf("%+v", err)
 			}
-			fmt.Printf("%s %s:%d: %s%s\n", estimation, r.Path, t.LineNumber, t.Extracted, suffix)
+			if err == nil {
+				existing.Merge(locked)
+				locked = existing
+			}
+		}
+		err := locked.Save(*lockFileFlag)
+		if err != nil {
+			log.Fatalf("%+v", err)
 		}
+		fmt.Fprintf(summary, "%d definitions have been locked in %s\n", len(locked.Entries), *lockFileFlag)
 	}
-	fmt.Println()
-	if isListMode {
-		fmt.Printf("%d/%d items will be replaced\n", changed, total)
-	} else {
-		fmt.Printf("%d/%d items have been replaced\n", changed, total)
+
+	if skipped > 0 {
+		fmt.Fprintf(summary, "%d items are skipped by directives\n", skipped)
 	}
 
 	if hasError || (isCheckMode && (changed > 0)) {
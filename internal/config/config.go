@@ -0,0 +1,74 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path"
+	"path/filepath"
+
+	"github.com/kachick/selfup/internal/policy"
+	"golang.org/x/xerrors"
+)
+
+const DefaultPath = "selfup.json"
+
+type Config struct {
+	// Nil means all commands are allowed
+	Policy *policy.Policy `json:"policy"`
+	// Additional prefixes for the matched files
+	Markers []Marker `json:"markers"`
+}
+
+// Marker is a pair of patterns(RE2) around definitions in the files
+type Marker struct {
+	Prefix string `json:"prefix"`
+	// Optional, like ` -->` in HTML comments
+	Suffix string `json:"suffix"`
+	// Globs for the paths or the base names, all files if empty
+	Files []string `json:"files"`
+}
+
+// Match reports whether the marker is used for the file
+func (m Marker) Match(p string) bool {
+	if len(m.Files) == 0 {
+		return true
+	}
+	slashed := filepath.ToSlash(filepath.Clean(p))
+	for _, pattern := range m.Files {
+		if matched, _ := path.Match(pattern, slashed); matched {
+			return true
+		}
+		if matched, _ := path.Match(pattern, path.Base(slashed)); matched {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Load reads the config. A missing file at the DefaultPath is treated as the empty config.
+func Load(path string) (Config, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) && path == DefaultPath {
+			return Config{}, nil
+		}
+		return Config{}, err
+	}
+
+	config := Config{}
+	dec := json.NewDecoder(bytes.NewReader(content))
+	dec.DisallowUnknownFields()
+	err = dec.Decode(&config)
+	if err != nil {
+		return Config{}, xerrors.Errorf("Unmarsharing `%s` has been failed: %w", path, err)
+	}
+	for _, m := range config.Markers {
+		if m.Prefix == "" {
+			return Config{}, xerrors.Errorf("Markers in `%s` should have the prefix", path)
+		}
+	}
+
+	return config, nil
+}
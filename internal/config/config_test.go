@@ -0,0 +1,114 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/kachick/selfup/internal/policy"
+)
+
+func TestLoad(t *testing.T) {
+	t.Run("Policy", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), DefaultPath)
+		err := os.WriteFile(path, []byte(`{ "policy": { "allow": [["dprint", "--version"], ["nix", "eval", "**"]] } }`), 0644)
+		if err != nil {
+			t.Fatalf("failed to create the config: %v", err)
+		}
+
+		config, err := Load(path)
+		if err != nil {
+			t.Fatalf("unexpected error happened: %v", err)
+		}
+		want := Config{Policy: &policy.Policy{Allow: []policy.Rule{{"dprint", "--version"}, {"nix", "eval", "**"}}}}
+		if diff := cmp.Diff(want, config); diff != "" {
+			t.Errorf("wrong result: %s", diff)
+		}
+	})
+
+	t.Run("Markers", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), DefaultPath)
+		err := os.WriteFile(path, []byte(`{ "markers": [{ "prefix": "\\s*<!-- selfup ", "suffix": " -->", "files": ["*.md", "docs/*.html"] }] }`), 0644)
+		if err != nil {
+			t.Fatalf("failed to create the config: %v", err)
+		}
+
+		config, err := Load(path)
+		if err != nil {
+			t.Fatalf("unexpected error happened: %v", err)
+		}
+		want := Config{Markers: []Marker{{Prefix: "\\s*<!-- selfup ", Suffix: " -->", Files: []string{"*.md", "docs/*.html"}}}}
+		if diff := cmp.Diff(want, config); diff != "" {
+			t.Errorf("wrong result: %s", diff)
+		}
+	})
+
+	t.Run("Marker without prefix", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), DefaultPath)
+		err := os.WriteFile(path, []byte(`{ "markers": [{ "suffix": " -->" }] }`), 0644)
+		if err != nil {
+			t.Fatalf("failed to create the config: %v", err)
+		}
+
+		_, err = Load(path)
+		if err == nil {
+			t.Fatalf("expected error did not happen")
+		}
+	})
+
+	t.Run("Unknown fields", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), DefaultPath)
+		err := os.WriteFile(path, []byte(`{ "polcy": {} }`), 0644)
+		if err != nil {
+			t.Fatalf("failed to create the config: %v", err)
+		}
+
+		_, err = Load(path)
+		if err == nil {
+			t.Fatalf("expected error did not happen")
+		}
+	})
+
+	t.Run("Missing default config", func(t *testing.T) {
+		t.Chdir(t.TempDir())
+		config, err := Load(DefaultPath)
+		if err != nil {
+			t.Fatalf("unexpected error happened: %v", err)
+		}
+		if config.Policy != nil {
+			t.Errorf("expected no policy, got %v", config.Policy)
+		}
+	})
+
+	t.Run("Missing specified config", func(t *testing.T) {
+		_, err := Load(filepath.Join(t.TempDir(), "missing.json"))
+		if err == nil {
+			t.Fatalf("expected error did not happen")
+		}
+	})
+}
+
+func TestMarkerMatch(t *testing.T) {
+	type testCase struct {
+		files []string
+		path  string
+		want  bool
+	}
+
+	testCases := map[string]testCase{
+		"no files":        {files: nil, path: "main.go", want: true},
+		"base name":       {files: []string{"*.md"}, path: "docs/README.md", want: true},
+		"path":            {files: []string{"docs/*.html"}, path: "./docs/index.html", want: true},
+		"other extension": {files: []string{"*.md", "*.html"}, path: "style.css", want: false},
+		"other directory": {files: []string{"docs/*.html"}, path: "site/index.html", want: false},
+	}
+
+	for what, tc := range testCases {
+		t.Run(what, func(t *testing.T) {
+			if got := (Marker{Prefix: "-- selfup ", Files: tc.files}).Match(tc.path); got != tc.want {
+				t.Errorf("wrong result: got %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
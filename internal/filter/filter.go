@@ -0,0 +1,166 @@
+package filter
+
+import (
+	"path"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/kachick/selfup/internal/runner"
+	"golang.org/x/xerrors"
+)
+
+// Filter selects the targets with the only and exclude conditions.
+// Each kind of only conditions narrows down the targets, and any exclude condition removes them.
+type Filter struct {
+	// Paths or globs like path.Match
+	OnlyFiles []string
+	// "path:N"
+	OnlyLines []string
+	// Executable names, full paths or whole argv joined with spaces
+	OnlyCommands []string
+	OnlyIDs      []string
+
+	ExcludeFiles    []string
+	ExcludeLines    []string
+	ExcludeCommands []string
+	ExcludeIDs      []string
+}
+
+// Line is a parsed "path:N"
+type Line struct {
+	Path   string
+	Number int
+}
+
+func parseLines(specs []string) ([]Line, error) {
+	lines := []Line{}
+	for _, spec := range specs {
+		// Paths can have ":"
+		i := strings.LastIndex(spec, ":")
+		if i < 1 {
+			return nil, xerrors.Errorf("Invalid line `%s`, it should be like `path:N`", spec)
+		}
+		p := spec[:i]
+		number, err := strconv.Atoi(spec[i+1:])
+		if err != nil || number < 1 {
+			return nil, xerrors.Errorf("Invalid line `%s`, it should be like `path:N`", spec)
+		}
+		lines = append(lines, Line{Path: filepath.Clean(p), Number: number})
+	}
+
+	return lines, nil
+}
+
+// Validate checks the syntax of lines
+func (f Filter) Validate() error {
+	_, err := parseLines(f.OnlyLines)
+	if err != nil {
+		return err
+	}
+	_, err = parseLines(f.ExcludeLines)
+	if err != nil {
+		return err
+	}
+	for _, pattern := range append(append([]string{}, f.OnlyFiles...), f.ExcludeFiles...) {
+		_, err := path.Match(filepath.ToSlash(pattern), "")
+		if err != nil {
+			return xerrors.Errorf("Invalid file pattern `%s`: %w", pattern, err)
+		}
+	}
+
+	return nil
+}
+
+func matchFile(patterns []string, p string) bool {
+	cleaned := filepath.ToSlash(filepath.Clean(p))
+	for _, pattern := range patterns {
+		pattern = filepath.ToSlash(filepath.Clean(pattern))
+		if pattern == cleaned {
+			return true
+		}
+		if matched, _ := path.Match(pattern, cleaned); matched {
+			return true
+		}
+	}
+
+	return false
+}
+
+func matchCommand(names []string, argv []string) bool {
+	if len(argv) == 0 {
+		return false
+	}
+	for _, name := range names {
+		if name == argv[0] || name == filepath.Base(argv[0]) || name == strings.Join(argv, " ") {
+			return true
+		}
+	}
+
+	return false
+}
+
+func matchLine(lines []Line, p string, number int) bool {
+	cleaned := filepath.Clean(p)
+	for _, l := range lines {
+		if l.Path == cleaned && l.Number == number {
+			return true
+		}
+	}
+
+	return false
+}
+
+func hasLineIn(lines []Line, p string) bool {
+	cleaned := filepath.Clean(p)
+	for _, l := range lines {
+		if l.Path == cleaned {
+			return true
+		}
+	}
+
+	return false
+}
+
+// File reports whether the file can have selected targets, to skip reading unrelated files
+func (f Filter) File(p string) bool {
+	if len(f.OnlyFiles) > 0 && !matchFile(f.OnlyFiles, p) {
+		return false
+	}
+	if matchFile(f.ExcludeFiles, p) {
+		return false
+	}
+	if len(f.OnlyLines) > 0 {
+		lines, _ := parseLines(f.OnlyLines)
+		return hasLineIn(lines, p)
+	}
+
+	return true
+}
+
+// Definitions returns the function for runner.Options.Filter in the file
+func (f Filter) Definitions(p string) func(lineNumber int, def runner.Definition) bool {
+	onlyLines, _ := parseLines(f.OnlyLines)
+	excludeLines, _ := parseLines(f.ExcludeLines)
+
+	return func(lineNumber int, def runner.Definition) bool {
+		if len(onlyLines) > 0 && !matchLine(onlyLines, p, lineNumber) {
+			return false
+		}
+		if len(f.OnlyCommands) > 0 && !matchCommand(f.OnlyCommands, def.Command) {
+			return false
+		}
+		if len(f.OnlyIDs) > 0 && !slices.Contains(f.OnlyIDs, def.ID) {
+			return false
+		}
+
+		return !(matchLine(excludeLines, p, lineNumber) || matchCommand(f.ExcludeCommands, def.Command) || slices.Contains(f.ExcludeIDs, def.ID))
+	}
+}
+
+// IsEmpty reports whether no conditions are given
+func (f Filter) IsEmpty() bool {
+	return len(f.OnlyFiles)+len(f.OnlyLines)+len(f.OnlyCommands)+len(f.OnlyIDs)+
+		len(f.ExcludeFiles)+len(f.ExcludeLines)+len(f.ExcludeCommands)+len(f.ExcludeIDs) == 0
+}
@@ -0,0 +1,112 @@
+package filter
+
+import (
+	"testing"
+
+	"github.com/kachick/selfup/internal/runner"
+)
+
+func TestFilter(t *testing.T) {
+	dprint := runner.Definition{Command: []string{"/usr/bin/dprint", "--version"}}
+	goreleaser := runner.Definition{ID: "goreleaser", Command: []string{"bash", "-c", "goreleaser --version"}}
+
+	type candidate struct {
+		path string
+		line int
+		def  runner.Definition
+	}
+	candidates := []candidate{
+		{".github/workflows/lint.yml", 17, dprint},
+		{".github/workflows/release.yml", 37, goreleaser},
+		{".github/workflows/release.yml", 50, dprint},
+		{"examples/simple.txt", 5, dprint},
+	}
+
+	type testCase struct {
+		filter Filter
+		want   []bool
+	}
+	testCases := map[string]testCase{
+		"Empty": {
+			filter: Filter{},
+			want:   []bool{true, true, true, true},
+		},
+		"Only file glob": {
+			filter: Filter{OnlyFiles: []string{".github/workflows/*.yml"}},
+			want:   []bool{true, true, true, false},
+		},
+		"Only line": {
+			filter: Filter{OnlyLines: []string{"./.github/workflows/release.yml:50"}},
+			want:   []bool{false, false, true, false},
+		},
+		"Only command by executable name": {
+			filter: Filter{OnlyCommands: []string{"dprint"}},
+			want:   []bool{true, false, true, true},
+		},
+		"Only command by whole argv": {
+			filter: Filter{OnlyCommands: []string{"bash -c goreleaser --version"}},
+			want:   []bool{false, true, false, false},
+		},
+		"Only ID": {
+			filter: Filter{OnlyIDs: []string{"goreleaser"}},
+			want:   []bool{false, true, false, false},
+		},
+		"Only conditions are intersected": {
+			filter: Filter{OnlyFiles: []string{".github/workflows/release.yml"}, OnlyCommands: []string{"dprint"}},
+			want:   []bool{false, false, true, false},
+		},
+		"Exclude file": {
+			filter: Filter{ExcludeFiles: []string{"examples/*"}},
+			want:   []bool{true, true, true, false},
+		},
+		"Exclude line": {
+			filter: Filter{ExcludeLines: []string{".github/workflows/lint.yml:17"}},
+			want:   []bool{false, true, true, true},
+		},
+		"Exclude command": {
+			filter: Filter{ExcludeCommands: []string{"dprint"}},
+			want:   []bool{false, true, false, false},
+		},
+		"Exclude ID": {
+			filter: Filter{ExcludeIDs: []string{"goreleaser"}},
+			want:   []bool{true, false, true, true},
+		},
+		"Exclude wins": {
+			filter: Filter{OnlyCommands: []string{"dprint"}, ExcludeFiles: []string{".github/workflows/lint.yml"}},
+			want:   []bool{false, false, true, true},
+		},
+	}
+
+	for what, tc := range testCases {
+		t.Run(what, func(t *testing.T) {
+			err := tc.filter.Validate()
+			if err != nil {
+				t.Fatalf("unexpected error happened: %v", err)
+			}
+			for i, c := range candidates {
+				got := tc.filter.File(c.path) && tc.filter.Definitions(c.path)(c.line, c.def)
+				if got != tc.want[i] {
+					t.Errorf("wrong result for %s:%d: %t", c.path, c.line, got)
+				}
+			}
+		})
+	}
+}
+
+func TestValidate(t *testing.T) {
+	for _, invalid := range []Filter{
+		{OnlyLines: []string{"lint.yml"}},
+		{OnlyLines: []string{":17"}},
+		{ExcludeLines: []string{"lint.yml:0"}},
+		{OnlyFiles: []string{"["}},
+	} {
+		if err := invalid.Validate(); err == nil {
+			t.Errorf("expected error did not happen: %v", invalid)
+		}
+	}
+
+	valid := Filter{OnlyLines: []string{"C:/lint.yml:17"}}
+	if err := valid.Validate(); err != nil {
+		t.Errorf("unexpected error happened: %v", err)
+	}
+}
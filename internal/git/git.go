@@ -0,0 +1,128 @@
+package git
+
+import (
+	"bytes"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/xerrors"
+)
+
+// Repository runs git in the directory
+type Repository struct {
+	Dir string
+}
+
+func (r Repository) run(args ...string) ([]byte, error) {
+	cmd := exec.Command("git", args...)
+	cmd.Dir = r.Dir
+	stderr := new(bytes.Buffer)
+	cmd.Stderr = stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, xerrors.Errorf("git %s has been failed: %s: %w", strings.Join(args, " "), strings.TrimSpace(stderr.String()), err)
+	}
+
+	return out, nil
+}
+
+func splitNull(out []byte) []string {
+	paths := []string{}
+	for path := range strings.SplitSeq(string(out), "\x00") {
+		if path != "" {
+			paths = append(paths, path)
+		}
+	}
+
+	return paths
+}
+
+// Skips deleted files and submodules, they cannot be processed as files
+func existingFiles(paths []string, dir string) []string {
+	files := []string{}
+	for _, path := range paths {
+		info, err := os.Stat(filepath.Join(dir, path))
+		if err != nil || !info.Mode().IsRegular() {
+			continue
+		}
+		files = append(files, path)
+	}
+
+	return files
+}
+
+func (r Repository) Root() (string, error) {
+	out, err := r.run("rev-parse", "--show-toplevel")
+	if err != nil {
+		return "", err
+	}
+
+	return strings.TrimSpace(string(out)), nil
+}
+
+// TrackedFiles returns files in the index that match the pathspecs, relative to the Dir
+func (r Repository) TrackedFiles(pathspecs ...string) ([]string, error) {
+	out, err := r.run(append([]string{"ls-files", "-z", "--"}, pathspecs...)...)
+	if err != nil {
+		return nil, err
+	}
+
+	return existingFiles(splitNull(out), r.Dir), nil
+}
+
+// ChangedFiles returns files that have been changed since the ref including uncommitted changes, relative to the Dir
+func (r Repository) ChangedFiles(ref string, pathspecs ...string) ([]string, error) {
+	// Avoids parsing the ref as an option like --output
+	if strings.HasPrefix(ref, "-") {
+		return nil, xerrors.Errorf("Invalid ref `%s`, it should not start with `-`", ref)
+	}
+	out, err := r.run(append([]string{"diff", "--name-only", "-z", "--diff-filter=d", ref, "--"}, pathspecs...)...)
+	if err != nil {
+		return nil, err
+	}
+	root, err := r.Root()
+	if err != nil {
+		return nil, err
+	}
+	dir, err := filepath.Abs(r.Dir)
+	if err != nil {
+		return nil, err
+	}
+	// git returns the resolved root, e.g. /private/var on macOS
+	dir, err = filepath.EvalSymlinks(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	// git diff returns paths from the root even if in sub directories
+	paths := []string{}
+	for _, path := range splitNull(out) {
+		relative, err := filepath.Rel(dir, filepath.Join(root, path))
+		if err != nil {
+			return nil, err
+		}
+		paths = append(paths, relative)
+	}
+
+	return existingFiles(paths, r.Dir), nil
+}
+
+// Stages only the given paths, other changes in the index are kept
+func (r Repository) Add(paths ...string) error {
+	_, err := r.run(append([]string{"add", "--"}, paths...)...)
+	return err
+}
+
+// Commits only the given paths even if other files are staged
+func (r Repository) Commit(message string, paths ...string) error {
+	_, err := r.run(append([]string{"commit", "--quiet", "--message", message, "--"}, paths...)...)
+	return err
+}
+
+// CreateBranch creates the branch from HEAD and switches to it
+func (r Repository) CreateBranch(name string) error {
+	_, err := r.run("switch", "--quiet", "--create", name)
+	return err
+}
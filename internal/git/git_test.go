@@ -0,0 +1,173 @@
+package git
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func setupRepository(t *testing.T) string {
+	t.Helper()
+
+	dir := t.TempDir()
+	t.Setenv("GIT_AUTHOR_NAME", "selfup")
+	t.Setenv("GIT_AUTHOR_EMAIL", "selfup@example.com")
+	t.Setenv("GIT_COMMITTER_NAME", "selfup")
+	t.Setenv("GIT_COMMITTER_EMAIL", "selfup@example.com")
+	t.Setenv("GIT_CONFIG_GLOBAL", os.DevNull)
+	t.Setenv("GIT_CONFIG_NOSYSTEM", "1")
+
+	git(t, dir, "init", "--quiet", "--initial-branch=main")
+	write(t, dir, "README.md", "# Example\n")
+	write(t, dir, ".github/workflows/lint.yml", "name: Lint\n")
+	write(t, dir, ".github/workflows/release.yml", "name: Release\n")
+	write(t, dir, "deleted.txt", "This will be deleted\n")
+	git(t, dir, "add", ".")
+	git(t, dir, "commit", "--quiet", "-m", "Initial commit")
+
+	return dir
+}
+
+func git(t *testing.T, dir string, args ...string) {
+	t.Helper()
+
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Fatalf("git %v has been failed: %v: %s", args, err, out)
+	}
+}
+
+func write(t *testing.T, dir string, path string, content string) {
+	t.Helper()
+
+	fullPath := filepath.Join(dir, path)
+	err := os.MkdirAll(filepath.Dir(fullPath), 0755)
+	if err != nil {
+		t.Fatalf("failed to create the directory: %v", err)
+	}
+	err = os.WriteFile(fullPath, []byte(content), 0644)
+	if err != nil {
+		t.Fatalf("failed to write the file: %v", err)
+	}
+}
+
+func TestTrackedFiles(t *testing.T) {
+	dir := setupRepository(t)
+	write(t, dir, "untracked.txt", "Not tracked\n")
+	err := os.Remove(filepath.Join(dir, "deleted.txt"))
+	if err != nil {
+		t.Fatalf("failed to remove the file: %v", err)
+	}
+
+	files, err := Repository{Dir: dir}.TrackedFiles()
+	if err != nil {
+		t.Fatalf("unexpected error happened: %v", err)
+	}
+	if diff := cmp.Diff([]string{".github/workflows/lint.yml", ".github/workflows/release.yml", "README.md"}, files); diff != "" {
+		t.Errorf("wrong result: %s", diff)
+	}
+
+	files, err = Repository{Dir: dir}.TrackedFiles(".github", ":!:*release*")
+	if err != nil {
+		t.Fatalf("unexpected error happened: %v", err)
+	}
+	if diff := cmp.Diff([]string{".github/workflows/lint.yml"}, files); diff != "" {
+		t.Errorf("wrong result with pathspecs: %s", diff)
+	}
+
+	files, err = Repository{Dir: filepath.Join(dir, ".github")}.TrackedFiles()
+	if err != nil {
+		t.Fatalf("unexpected error happened: %v", err)
+	}
+	if diff := cmp.Diff([]string{"workflows/lint.yml", "workflows/release.yml"}, files); diff != "" {
+		t.Errorf("wrong result in sub directory: %s", diff)
+	}
+}
+
+func TestChangedFiles(t *testing.T) {
+	dir := setupRepository(t)
+	write(t, dir, ".github/workflows/lint.yml", "name: Lint with changes\n")
+	git(t, dir, "commit", "--quiet", "-am", "Committed change")
+	write(t, dir, "README.md", "# Uncommitted change\n")
+	write(t, dir, "staged.txt", "Staged\n")
+	git(t, dir, "add", "staged.txt")
+	git(t, dir, "rm", "--quiet", "deleted.txt")
+
+	files, err := Repository{Dir: dir}.ChangedFiles("HEAD~1")
+	if err != nil {
+		t.Fatalf("unexpected error happened: %v", err)
+	}
+	if diff := cmp.Diff([]string{".github/workflows/lint.yml", "README.md", "staged.txt"}, files); diff != "" {
+		t.Errorf("wrong result: %s", diff)
+	}
+
+	files, err = Repository{Dir: filepath.Join(dir, ".github")}.ChangedFiles("HEAD~1")
+	if err != nil {
+		t.Fatalf("unexpected error happened: %v", err)
+	}
+	if diff := cmp.Diff([]string{"workflows/lint.yml", "../README.md", "../staged.txt"}, files); diff != "" {
+		t.Errorf("wrong result in sub directory: %s", diff)
+	}
+
+	_, err = Repository{Dir: dir}.ChangedFiles("unknown-ref")
+	if err == nil {
+		t.Fatalf("expected error did not happen")
+	}
+
+	output := filepath.Join(t.TempDir(), "written")
+	_, err = Repository{Dir: dir}.ChangedFiles("--output=" + output)
+	if err == nil {
+		t.Fatalf("expected error did not happen")
+	}
+	if _, err := os.Stat(output); err == nil {
+		t.Errorf("the ref should not be parsed as an option")
+	}
+}
+
+func TestCommit(t *testing.T) {
+	dir := setupRepository(t)
+	repo := Repository{Dir: dir}
+	write(t, dir, ".github/workflows/lint.yml", "name: Lint with changes\n")
+	write(t, dir, "README.md", "# Not committed\n")
+	write(t, dir, "staged.txt", "Staged by others\n")
+	git(t, dir, "add", "staged.txt")
+
+	err := repo.CreateBranch("selfup-update")
+	if err != nil {
+		t.Fatalf("unexpected error happened: %v", err)
+	}
+	err = repo.Add(".github/workflows/lint.yml")
+	if err != nil {
+		t.Fatalf("unexpected error happened: %v", err)
+	}
+	err = repo.Commit("Update lint.yml", ".github/workflows/lint.yml")
+	if err != nil {
+		t.Fatalf("unexpected error happened: %v", err)
+	}
+
+	out, err := repo.run("show", "--name-only", "--format=%s%n%D", "HEAD")
+	if err != nil {
+		t.Fatalf("unexpected error happened: %v", err)
+	}
+	if diff := cmp.Diff("Update lint.yml\nHEAD -> selfup-update\n\n.github/workflows/lint.yml\n", string(out)); diff != "" {
+		t.Errorf("wrong commit: %s", diff)
+	}
+
+	changed, err := repo.ChangedFiles("HEAD")
+	if err != nil {
+		t.Fatalf("unexpected error happened: %v", err)
+	}
+	if diff := cmp.Diff([]string{"README.md", "staged.txt"}, changed); diff != "" {
+		t.Errorf("other changes should be kept: %s", diff)
+	}
+
+	err = repo.CreateBranch("selfup-update")
+	if err == nil {
+		t.Fatalf("expected error did not happen for existing branch")
+	}
+}
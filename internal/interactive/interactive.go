@@ -0,0 +1,128 @@
+package interactive
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/kachick/selfup/internal/runner"
+	"golang.org/x/xerrors"
+)
+
+// Number of lines around the target in the preview
+const contextLines = 1
+
+// Session asks which changes should be applied, and remembers the answers for the replacers
+type Session struct {
+	in       *bufio.Reader
+	out      io.Writer
+	accepted map[string]bool
+	isQuit   bool
+}
+
+func NewSession(in io.Reader, out io.Writer) *Session {
+	return &Session{in: bufio.NewReader(in), out: out, accepted: map[string]bool{}}
+}
+
+type answer int
+
+const (
+	accept answer = iota
+	skip
+	acceptAll
+	quit
+)
+
+func (s *Session) ask(command string) (answer, error) {
+	for {
+		fmt.Fprintf(s.out, "Apply this change? [y]es, [n]o, [a]ll for `%s`, [q]uit: ", command)
+		input, err := s.in.ReadString('\n')
+		if err != nil {
+			if !xerrors.Is(err, io.EOF) {
+				return quit, xerrors.Errorf("Reading the answer has been failed: %w", err)
+			}
+			// Closed input without answers
+			if input == "" {
+				fmt.Fprintln(s.out)
+				return quit, nil
+			}
+		}
+		switch strings.ToLower(strings.TrimSpace(input)) {
+		case "y", "yes":
+			return accept, nil
+		case "n", "no":
+			return skip, nil
+		case "a", "all":
+			return acceptAll, nil
+		case "q", "quit":
+			return quit, nil
+		}
+	}
+}
+
+// Reverts the replaced value in the new line, the value starts at the same offset in both lines
+func revert(newLine string, t runner.Target) string {
+	start := t.ValueBytes.Start
+	return newLine[:start] + t.Extracted + newLine[start+len(t.Replacer):]
+}
+
+func (s *Session) preview(path string, result runner.Result, t runner.Target) {
+	index := t.LineNumber - 1
+	fmt.Fprintf(s.out, "%s:%d:%d: %s %s => %s\n", path, t.LineNumber, t.ValueRunes.Start+1, t.Name(), t.Extracted, t.Replacer)
+	for i := max(index-contextLines, 0); i < index; i++ {
+		fmt.Fprintf(s.out, "  %d | %s\n", i+1, result.NewLines[i])
+	}
+	fmt.Fprintf(s.out, "- %d | %s\n", t.LineNumber, revert(result.NewLines[index], t))
+	fmt.Fprintf(s.out, "+ %d | %s\n", t.LineNumber, result.NewLines[index])
+	for i := index + 1; i < min(index+1+contextLines, len(result.NewLines)); i++ {
+		fmt.Fprintf(s.out, "  %d | %s\n", i+1, result.NewLines[i])
+	}
+}
+
+// Review asks for each change in the file and returns the result only with the accepted changes.
+// Skipped changes are reverted in NewLines and marked as unchanged.
+func (s *Session) Review(path string, result runner.Result) (runner.Result, error) {
+	reviewed := runner.Result{
+		NewLines:     append([]string{}, result.NewLines...),
+		Targets:      make([]runner.Target, 0, len(result.Targets)),
+		ChangedCount: 0,
+		Total:        result.Total,
+		Skipped:      result.Skipped,
+	}
+	for _, t := range result.Targets {
+		if !t.IsChanged {
+			reviewed.Targets = append(reviewed.Targets, t)
+			continue
+		}
+
+		command := strings.Join(t.Command, " ")
+		isAccepted := s.accepted[command]
+		if !isAccepted && !s.isQuit {
+			s.preview(path, result, t)
+			answer, err := s.ask(command)
+			if err != nil {
+				return runner.Result{}, err
+			}
+			switch answer {
+			case accept:
+				isAccepted = true
+			case acceptAll:
+				isAccepted = true
+				s.accepted[command] = true
+			case quit:
+				s.isQuit = true
+			}
+		}
+
+		if isAccepted {
+			reviewed.ChangedCount++
+		} else {
+			reviewed.NewLines[t.LineNumber-1] = revert(result.NewLines[t.LineNumber-1], t)
+			t.IsChanged = false
+		}
+		reviewed.Targets = append(reviewed.Targets, t)
+	}
+
+	return reviewed, nil
+}
@@ -0,0 +1,145 @@
+package interactive
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/kachick/selfup/internal/runner"
+)
+
+// Planned by runner.DryRun, every value is replaced
+var planned = runner.Result{
+	NewLines: []string{
+		`dprint: '0.40.2' # selfup { "extract": "\\d[^']+", "replacer": ["dprint", "--version"], "nth": 2 }`,
+		`typos: '1.16.0' # selfup { "extract": "\\d[^']+", "replacer": ["typos", "--version"], "nth": 2 }`,
+		`latest: '1.0.0' # selfup { "extract": "\\d[^']+", "replacer": ["echo", "1.0.0"] }`,
+
+		`ignored: '0.1.0' # selfup { "extract": "\\d[^']+", "replacer": ["echo", "0.2.0"] } # selfup-ignore`,
+	},
+	Targets: []runner.Target{
+		{LineNumber: 1, Extracted: "0.39.0", Replacer: "0.40.2", IsChanged: true, Command: []string{"dprint", "--version"}, ValueBytes: runner.Span{Start: 9, End: 15}, ValueRunes: runner.Span{Start: 9, End: 15}},
+		{LineNumber: 2, Extracted: "1.10.9", Replacer: "1.16.0", IsChanged: true, Command: []string{"typos", "--version"}, ValueBytes: runner.Span{Start: 8, End: 14}, ValueRunes: runner.Span{Start: 8, End: 14}},
+		{LineNumber: 3, Extracted: "1.0.0", Replacer: "1.0.0", Command: []string{"echo", "1.0.0"}, ValueBytes: runner.Span{Start: 9, End: 14}, ValueRunes: runner.Span{Start: 9, End: 14}},
+	},
+	ChangedCount: 2,
+	Total:        3,
+	Skipped:      []runner.Skipped{{LineNumber: 4, Directive: "selfup-ignore"}},
+}
+
+func TestReview(t *testing.T) {
+	type testCase struct {
+		input     string
+		wantLines []string
+		wantCount int
+	}
+	testCases := map[string]testCase{
+		"Accept all": {
+			input:     "y\ny\n",
+			wantLines: planned.NewLines,
+			wantCount: 2,
+		},
+		"Skip one": {
+			input: "n\nyes\n",
+			wantLines: []string{
+				`dprint: '0.39.0' # selfup { "extract": "\\d[^']+", "replacer": ["dprint", "--version"], "nth": 2 }`,
+				planned.NewLines[1],
+				planned.NewLines[2],
+				planned.NewLines[3],
+			},
+			wantCount: 1,
+		},
+		"Ask again for unknown answers": {
+			input:     "maybe\n\nY\nn\n",
+			wantLines: []string{planned.NewLines[0], `typos: '1.10.9' # selfup { "extract": "\\d[^']+", "replacer": ["typos", "--version"], "nth": 2 }`, planned.NewLines[2], planned.NewLines[3]},
+			wantCount: 1,
+		},
+		"Quit": {
+			input: "q\n",
+			wantLines: []string{
+				`dprint: '0.39.0' # selfup { "extract": "\\d[^']+", "replacer": ["dprint", "--version"], "nth": 2 }`,
+				`typos: '1.10.9' # selfup { "extract": "\\d[^']+", "replacer": ["typos", "--version"], "nth": 2 }`,
+				planned.NewLines[2],
+				planned.NewLines[3],
+			},
+			wantCount: 0,
+		},
+		"Closed input": {
+			input: "y",
+			wantLines: []string{
+				planned.NewLines[0],
+				`typos: '1.10.9' # selfup { "extract": "\\d[^']+", "replacer": ["typos", "--version"], "nth": 2 }`,
+				planned.NewLines[2],
+				planned.NewLines[3],
+			},
+			wantCount: 1,
+		},
+	}
+
+	for what, tc := range testCases {
+		t.Run(what, func(t *testing.T) {
+			session := NewSession(strings.NewReader(tc.input), new(strings.Builder))
+			reviewed, err := session.Review("lint.yml", planned)
+			if err != nil {
+				t.Fatalf("unexpected error happened: %v", err)
+			}
+			if diff := cmp.Diff(tc.wantLines, reviewed.NewLines); diff != "" {
+				t.Errorf("wrong result: %s", diff)
+			}
+			if diff := cmp.Diff(planned.Skipped, reviewed.Skipped); diff != "" {
+				t.Errorf("skipped definitions should be kept: %s", diff)
+			}
+			if reviewed.ChangedCount != tc.wantCount {
+				t.Errorf("wrong changed count: %d", reviewed.ChangedCount)
+			}
+			changed := 0
+			for _, target := range reviewed.Targets {
+				if target.IsChanged {
+					changed++
+				}
+			}
+			if changed != tc.wantCount {
+				t.Errorf("wrong changed targets: %d", changed)
+			}
+		})
+	}
+}
+
+func TestReviewAcceptAllForReplacer(t *testing.T) {
+	out := new(strings.Builder)
+	session := NewSession(strings.NewReader("a\nn\nn\n"), out)
+	for _, path := range []string{"lint.yml", "release.yml"} {
+		reviewed, err := session.Review(path, planned)
+		if err != nil {
+			t.Fatalf("unexpected error happened: %v", err)
+		}
+		if !reviewed.Targets[0].IsChanged || reviewed.Targets[1].IsChanged {
+			t.Errorf("wrong result in %s: %v", path, reviewed.Targets)
+		}
+	}
+	if count := strings.Count(out.String(), "Apply this change?"); count != 3 {
+		t.Errorf("expected dprint is asked only once, but asked %d times: %s", count, out.String())
+	}
+}
+
+func TestPreview(t *testing.T) {
+	out := new(strings.Builder)
+	session := NewSession(strings.NewReader("y\n"), out)
+	_, err := session.Review("lint.yml", runner.Result{
+		NewLines:     planned.NewLines,
+		Targets:      planned.Targets[:1],
+		ChangedCount: 1,
+		Total:        1,
+	})
+	if err != nil {
+		t.Fatalf("unexpected error happened: %v", err)
+	}
+	want := "lint.yml:1:10: dprint 0.39.0 => 0.40.2\n" +
+		"- 1 | dprint: '0.39.0' # selfup { \"extract\": \"\\\\d[^']+\", \"replacer\": [\"dprint\", \"--version\"], \"nth\": 2 }\n" +
+		"+ 1 | dprint: '0.40.2' # selfup { \"extract\": \"\\\\d[^']+\", \"replacer\": [\"dprint\", \"--version\"], \"nth\": 2 }\n" +
+		"  2 | typos: '1.16.0' # selfup { \"extract\": \"\\\\d[^']+\", \"replacer\": [\"typos\", \"--version\"], \"nth\": 2 }\n" +
+		"Apply this change? [y]es, [n]o, [a]ll for `dprint --version`, [q]uit: "
+	if diff := cmp.Diff(want, out.String()); diff != "" {
+		t.Errorf("wrong result: %s", diff)
+	}
+}
@@ -0,0 +1,177 @@
+package lock
+
+import (
+	"encoding/json"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/kachick/selfup/internal/runner"
+	"golang.org/x/xerrors"
+)
+
+const (
+	Version     = 1
+	DefaultPath = "selfup.lock"
+)
+
+type Entry struct {
+	Command   []string    `json:"replacer"`
+	Nth       int         `json:"nth,omitempty"`
+	Delimiter string      `json:"delimiter,omitempty"`
+	Cwd       string      `json:"cwd,omitempty"`
+	Env       *runner.Env `json:"env,omitempty"`
+	Value     string      `json:"value"`
+}
+
+func (e Entry) isFor(def runner.Definition) bool {
+	return slices.Equal(e.Command, def.Command) && e.Nth == def.Nth && e.Delimiter == def.Delimiter &&
+		e.Cwd == def.Cwd && reflect.DeepEqual(e.Env, def.Env)
+}
+
+type Lock struct {
+	Version int              `json:"version"`
+	Entries map[string]Entry `json:"entries"`
+}
+
+func New() *Lock {
+	return &Lock{
+		Version: Version,
+		Entries: map[string]Entry{},
+	}
+}
+
+func Load(path string) (*Lock, error) {
+	bytes, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	l := new(Lock)
+	err = json.Unmarshal(bytes, l)
+	if err != nil {
+		return nil, xerrors.Errorf("Unmarsharing `%s` has been failed: %w", path, err)
+	}
+	if l.Version != Version {
+		return nil, xerrors.Errorf("Unsupported lock version %d in `%s`, expected %d", l.Version, path, Version)
+	}
+	if l.Entries == nil {
+		l.Entries = map[string]Entry{}
+	}
+
+	return l, nil
+}
+
+// Merge overwrites the entries with the other lock, and keeps the entries that are not in the other
+func (l *Lock) Merge(other *Lock) {
+	for key, entry := range other.Entries {
+		l.Entries[key] = entry
+	}
+}
+
+func (l *Lock) Save(path string) error {
+	bytes, err := json.MarshalIndent(l, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(path, append(bytes, '\n'), fs.ModePerm)
+}
+
+// Relative cwd depends on the annotated file, so it is keyed by the path from the repository root like "/tools"
+func normalize(def runner.Definition, cmd runner.Command, root string) runner.Definition {
+	if def.Cwd == "" || strings.HasPrefix(def.Cwd, "/") || root == "" {
+		return def
+	}
+	dir, err := filepath.Abs(cmd.Dir)
+	if err != nil {
+		return def
+	}
+	rel, err := filepath.Rel(root, dir)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return def
+	}
+	def.Cwd = path.Join("/", filepath.ToSlash(rel))
+
+	return def
+}
+
+// Resolver applies the locked values without executing any command
+type Resolver struct {
+	Lock *Lock
+	// Repository root for relative cwd in definitions, they are keyed as written if empty
+	Root string
+}
+
+func (r Resolver) Resolve(def runner.Definition, cmd runner.Command) (string, error) {
+	def = normalize(def, cmd, r.Root)
+	key := def.Key()
+	entry, ok := r.Lock.Entries[key]
+	if !ok {
+		return "", xerrors.Errorf("`%s` is not locked, run `selfup lock` again", key)
+	}
+	if !entry.isFor(def) {
+		return "", xerrors.Errorf("`%s` has been changed since locked, run `selfup lock` again", key)
+	}
+
+	return entry.Value, nil
+}
+
+// Recorder resolves values with the wrapped resolver and keeps them for the lock file
+type Recorder struct {
+	next runner.Resolver
+	root string
+	lock *Lock
+	mu   sync.Mutex
+}
+
+// The root is the repository root for relative cwd in definitions as in Resolver
+func NewRecorder(next runner.Resolver, root string) *Recorder {
+	return &Recorder{
+		next: next,
+		root: root,
+		lock: New(),
+	}
+}
+
+func (r *Recorder) Resolve(def runner.Definition, cmd runner.Command) (string, error) {
+	value, err := r.next.Resolve(def, cmd)
+	if err != nil {
+		return "", err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	def = normalize(def, cmd, r.root)
+	key := def.Key()
+	if recorded, ok := r.lock.Entries[key]; ok {
+		if !recorded.isFor(def) {
+			return "", xerrors.Errorf("`%s` is used for different replacers: %v and %v", key, recorded.Command, def.Command)
+		}
+		if recorded.Value != value {
+			return "", xerrors.Errorf("`%s` resolved different values: %s and %s", key, recorded.Value, value)
+		}
+	}
+	r.lock.Entries[key] = Entry{
+		Command:   def.Command,
+		Nth:       def.Nth,
+		Delimiter: def.Delimiter,
+		Cwd:       def.Cwd,
+		Env:       def.Env,
+		Value:     value,
+	}
+
+	return value, nil
+}
+
+func (r *Recorder) Lock() *Lock {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.lock
+}
@@ -0,0 +1,154 @@
+package lock
+
+import (
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/kachick/selfup/internal/runner"
+)
+
+const defaultPrefix string = "\\s*[#;/]* selfup "
+
+func TestRecordAndApply(t *testing.T) {
+	prefix := regexp.MustCompile(defaultPrefix)
+	input := `will_be_replaced: '0.39.0' # selfup { "extract": "\\d[^']+", "replacer": ["echo", "0.76.9"] }
+with_id: '1.2.3' # selfup { "id": "supertool", "extract": "\\d[^']+", "replacer": ["echo", "supertool 1.2.4"], "nth": 2 }
+`
+
+	executor := runner.FakeExecutor{Outputs: map[string]string{
+		"echo 0.76.9":          "0.76.9\n",
+		"echo supertool 1.2.4": "supertool 1.2.4\n",
+	}}
+	recorder := NewRecorder(runner.CommandResolver{Executor: executor}, "")
+	recorded, err := runner.DryRunWith(strings.NewReader(input), runner.Options{Prefix: prefix, Resolver: recorder})
+	if err != nil {
+		t.Fatalf("unexpected error happened: %v", err)
+	}
+
+	path := filepath.Join(t.TempDir(), DefaultPath)
+	err = recorder.Lock().Save(path)
+	if err != nil {
+		t.Fatalf("unexpected error happened: %v", err)
+	}
+	loaded, err := Load(path)
+	if err != nil {
+		t.Fatalf("unexpected error happened: %v", err)
+	}
+
+	if diff := cmp.Diff(Entry{Command: []string{"echo", "supertool 1.2.4"}, Nth: 2, Value: "1.2.4"}, loaded.Entries["supertool"]); diff != "" {
+		t.Errorf("wrong entry: %s", diff)
+	}
+	if len(loaded.Entries) != 2 {
+		t.Errorf("expected 2 entries, got %d", len(loaded.Entries))
+	}
+
+	applied, err := runner.DryRunWith(strings.NewReader(input), runner.Options{Prefix: prefix, Resolver: Resolver{Lock: loaded}})
+	if err != nil {
+		t.Fatalf("unexpected error happened: %v", err)
+	}
+	if diff := cmp.Diff(recorded, applied); diff != "" {
+		t.Errorf("wrong result: %s", diff)
+	}
+}
+
+func TestResolver(t *testing.T) {
+	def := runner.Definition{Extract: "\\d+", Command: []string{"this_command_does_not_exist"}}
+	l := New()
+	l.Entries[def.Key()] = Entry{Command: def.Command, Value: "42"}
+
+	t.Run("Locked", func(t *testing.T) {
+		value, err := Resolver{Lock: l}.Resolve(def, runner.Command{})
+		if err != nil {
+			t.Fatalf("unexpected error happened: %v", err)
+		}
+		if value != "42" {
+			t.Errorf("expected 42, got %s", value)
+		}
+	})
+
+	t.Run("Not locked", func(t *testing.T) {
+		_, err := Resolver{Lock: l}.Resolve(runner.Definition{Command: []string{"echo", "42"}}, runner.Command{})
+		if err == nil {
+			t.Fatalf("expected error did not happen")
+		}
+	})
+
+	t.Run("Changed since locked", func(t *testing.T) {
+		_, err := Resolver{Lock: l}.Resolve(runner.Definition{ID: def.Key(), Command: []string{"echo", "42"}}, runner.Command{})
+		if err == nil {
+			t.Fatalf("expected error did not happen")
+		}
+	})
+}
+
+func TestRecorderConflicts(t *testing.T) {
+	executor := runner.FakeExecutor{Outputs: map[string]string{"echo 1": "1\n", "echo 2": "2\n"}}
+	recorder := NewRecorder(runner.CommandResolver{Executor: executor}, "")
+	_, err := recorder.Resolve(runner.Definition{ID: "tool", Command: []string{"echo", "1"}}, runner.Command{Argv: []string{"echo", "1"}})
+	if err != nil {
+		t.Fatalf("unexpected error happened: %v", err)
+	}
+	_, err = recorder.Resolve(runner.Definition{ID: "tool", Command: []string{"echo", "2"}}, runner.Command{Argv: []string{"echo", "2"}})
+	if err == nil {
+		t.Fatalf("expected error did not happen")
+	}
+}
+
+func TestMerge(t *testing.T) {
+	l := New()
+	l.Entries["dprint"] = Entry{Command: []string{"dprint", "--version"}, Value: "0.39.0"}
+	l.Entries["typos"] = Entry{Command: []string{"typos", "--version"}, Value: "1.10.9"}
+	filtered := New()
+	filtered.Entries["dprint"] = Entry{Command: []string{"dprint", "--version"}, Value: "0.40.2"}
+
+	l.Merge(filtered)
+	want := map[string]Entry{
+		"dprint": {Command: []string{"dprint", "--version"}, Value: "0.40.2"},
+		"typos":  {Command: []string{"typos", "--version"}, Value: "1.10.9"},
+	}
+	if diff := cmp.Diff(want, l.Entries); diff != "" {
+		t.Errorf("wrong entries: %s", diff)
+	}
+}
+
+func TestRelativeCwd(t *testing.T) {
+	prefix := regexp.MustCompile(defaultPrefix)
+	input := `version: '0.1.0' # selfup { "extract": "[\\d.]+", "replacer": ["cat", "VERSION"], "cwd": "." }
+`
+	root := t.TempDir()
+	versions := map[string]string{"a": "1.0.0", "b": "2.0.0"}
+	executor := runner.ExecutorFunc(func(cmd runner.Command) ([]byte, error) {
+		return []byte(versions[filepath.Base(cmd.Dir)] + "\n"), nil
+	})
+	for dir := range versions {
+		err := os.Mkdir(filepath.Join(root, dir), 0755)
+		if err != nil {
+			t.Fatalf("unexpected error happened: %v", err)
+		}
+	}
+
+	recorder := NewRecorder(runner.CommandResolver{Executor: executor}, root)
+	for dir := range versions {
+		_, err := runner.DryRunWith(strings.NewReader(input), runner.Options{Prefix: prefix, Resolver: recorder, Dir: filepath.Join(root, dir), Root: root})
+		if err != nil {
+			t.Fatalf("same definitions in different directories should be recorded separately: %v", err)
+		}
+	}
+	if len(recorder.Lock().Entries) != 2 {
+		t.Errorf("expected 2 entries, got %d", len(recorder.Lock().Entries))
+	}
+
+	for dir, version := range versions {
+		result, err := runner.DryRunWith(strings.NewReader(input), runner.Options{Prefix: prefix, Resolver: Resolver{Lock: recorder.Lock(), Root: root}, Dir: filepath.Join(root, dir), Root: root})
+		if err != nil {
+			t.Fatalf("unexpected error happened: %v", err)
+		}
+		if result.Targets[0].Replacer != version {
+			t.Errorf("wrong value in %s: %s", dir, result.Targets[0].Replacer)
+		}
+	}
+}
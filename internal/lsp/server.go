@@ -0,0 +1,401 @@
+package lsp
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"maps"
+	"net/url"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"unicode/utf16"
+
+	"github.com/kachick/selfup/internal/runner"
+	"github.com/kachick/selfup/internal/schema"
+	"github.com/kachick/selfup/internal/structured"
+	"github.com/kachick/selfup/internal/syntax"
+	"golang.org/x/xerrors"
+)
+
+// Server is a Language Server for the annotations.
+// It handles requests sequentially, so replacers run one by one.
+type Server struct {
+	// Base options to plan each line, Dir is overridden with the directory of the document
+	Options runner.Options
+	// Optional, overrides Prefix and Suffix in Options for the document path
+	Markers func(path string) (prefix *regexp.Regexp, suffix *regexp.Regexp)
+	// Handles only the definitions in comments of the languages detected from the document paths
+	CommentsOnly bool
+	Version      string
+
+	documents  map[string]string
+	out        io.Writer
+	isShutdown bool
+}
+
+// Serve handles the messages until the exit notification or the end of r
+func (s *Server) Serve(r io.Reader, w io.Writer) error {
+	s.documents = map[string]string{}
+	s.out = w
+	reader := bufio.NewReader(r)
+	for {
+		body, err := readMessage(reader)
+		if err != nil {
+			if xerrors.Is(err, io.EOF) {
+				return nil
+			}
+			return err
+		}
+
+		var req request
+		err = json.Unmarshal(body, &req)
+		if err != nil {
+			err = s.replyError(nil, codeParseError, err.Error())
+			if err != nil {
+				return err
+			}
+			continue
+		}
+		if req.Method == "exit" {
+			return nil
+		}
+
+		var result any
+		if s.isShutdown {
+			err = &responseError{Code: codeInvalidRequest, Message: "The server has been shut down"}
+		} else {
+			result, err = s.handle(req)
+		}
+		if req.isNotification() {
+			continue
+		}
+		if err != nil {
+			var rpcErr *responseError
+			if !xerrors.As(err, &rpcErr) {
+				rpcErr = &responseError{Code: codeInternalError, Message: err.Error()}
+			}
+			err = s.replyError(req.ID, rpcErr.Code, rpcErr.Message)
+		} else {
+			err = writeMessage(s.out, response{JSONRPC: "2.0", ID: *req.ID, Result: result})
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+func (s *Server) replyError(id *json.RawMessage, code int, message string) error {
+	rawID := json.RawMessage("null")
+	if id != nil {
+		rawID = *id
+	}
+
+	return writeMessage(s.out, errorResponse{JSONRPC: "2.0", ID: rawID, Error: responseError{Code: code, Message: message}})
+}
+
+func decodeParams[T any](req request) (T, error) {
+	var params T
+	err := json.Unmarshal(req.Params, &params)
+	if err != nil {
+		return params, &responseError{Code: codeInvalidParams, Message: err.Error()}
+	}
+
+	return params, nil
+}
+
+func (s *Server) handle(req request) (any, error) {
+	switch req.Method {
+	case "initialize":
+		return InitializeResult{
+			Capabilities: ServerCapabilities{
+				TextDocumentSync:   syncFull,
+				HoverProvider:      true,
+				CodeActionProvider: true,
+				CompletionProvider: &CompletionOptions{TriggerCharacters: []string{"\""}},
+			},
+			ServerInfo: ServerInfo{Name: "selfup", Version: s.Version},
+		}, nil
+	case "initialized":
+		return nil, nil
+	case "shutdown":
+		s.isShutdown = true
+		return nil, nil
+	case "textDocument/didOpen":
+		params, err := decodeParams[DidOpenTextDocumentParams](req)
+		if err != nil {
+			return nil, err
+		}
+		s.documents[params.TextDocument.URI] = params.TextDocument.Text
+		return nil, s.publishDiagnostics(params.TextDocument.URI)
+	case "textDocument/didChange":
+		params, err := decodeParams[DidChangeTextDocumentParams](req)
+		if err != nil {
+			return nil, err
+		}
+		if len(params.ContentChanges) == 0 {
+			return nil, nil
+		}
+		s.documents[params.TextDocument.URI] = params.ContentChanges[len(params.ContentChanges)-1].Text
+		return nil, s.publishDiagnostics(params.TextDocument.URI)
+	case "textDocument/didClose":
+		params, err := decodeParams[DidCloseTextDocumentParams](req)
+		if err != nil {
+			return nil, err
+		}
+		delete(s.documents, params.TextDocument.URI)
+		return nil, writeMessage(s.out, notification{
+			JSONRPC: "2.0",
+			Method:  "textDocument/publishDiagnostics",
+			Params:  PublishDiagnosticsParams{URI: params.TextDocument.URI, Diagnostics: []Diagnostic{}},
+		})
+	case "textDocument/hover":
+		params, err := decodeParams[TextDocumentPositionParams](req)
+		if err != nil {
+			return nil, err
+		}
+		return s.hover(params)
+	case "textDocument/codeAction":
+		params, err := decodeParams[CodeActionParams](req)
+		if err != nil {
+			return nil, err
+		}
+		return s.codeActions(params), nil
+	case "textDocument/completion":
+		params, err := decodeParams[TextDocumentPositionParams](req)
+		if err != nil {
+			return nil, err
+		}
+		return s.completion(params)
+	default:
+		return nil, &responseError{Code: codeMethodNotFound, Message: fmt.Sprintf("Unsupported method `%s`", req.Method)}
+	}
+}
+
+func lines(text string) []string {
+	lines := strings.Split(text, "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimSuffix(line, "\r")
+	}
+
+	return lines
+}
+
+// LSP counts characters in UTF-16 code units by default
+func utf16Length(s string) int {
+	return len(utf16.Encode([]rune(s)))
+}
+
+func byteOffset(line string, character int) int {
+	units := 0
+	for i, r := range line {
+		if units >= character {
+			return i
+		}
+		units += utf16.RuneLen(r)
+	}
+
+	return len(line)
+}
+
+func position(line string, lineIndex int, offset int) Position {
+	return Position{Line: lineIndex, Character: utf16Length(line[:offset])}
+}
+
+func (s *Server) line(uri string, index int) (string, bool) {
+	text, ok := s.documents[uri]
+	if !ok {
+		return "", false
+	}
+	lines := lines(text)
+	if index < 0 || index >= len(lines) {
+		return "", false
+	}
+
+	return lines[index], true
+}
+
+// Options for the document
+func (s *Server) options(uri string) runner.Options {
+	opts := s.Options
+	parsed, err := url.Parse(uri)
+	if err != nil || parsed.Scheme != "file" {
+		return opts
+	}
+	path := filepath.FromSlash(parsed.Path)
+	opts.Dir = filepath.Dir(path)
+	if s.Markers != nil {
+		opts.Prefix, opts.Suffix = s.Markers(path)
+	}
+	if s.CommentsOnly {
+		opts.Language = syntax.ForPath(path)
+	}
+	opts.Format = structured.ForPath(path)
+
+	return opts
+}
+
+// Returns the byte offset of the definition JSON if the line has it
+func (s *Server) annotationOffset(uri string, index int, line string) (int, bool) {
+	opts := s.options(uri)
+	offset, ok := runner.AnnotationOffset(line, opts.Prefix)
+	if !ok || opts.Language == nil {
+		return offset, ok
+	}
+	// Comments and strings can start in the previous lines
+	comments := opts.Language.Comments(lines(s.documents[uri]))
+	if index >= len(comments) || !syntax.Contains(comments[index], offset) {
+		return 0, false
+	}
+
+	return offset, true
+}
+
+func (s *Server) publishDiagnostics(uri string) error {
+	text := s.documents[uri]
+	opts := s.options(uri)
+	problems, err := runner.ValidateWith(strings.NewReader(text), opts)
+	if err != nil {
+		return err
+	}
+	lines := lines(text)
+	diagnostics := []Diagnostic{}
+	for _, p := range problems {
+		index := p.Line - 1
+		line := lines[index]
+		// Columns of problems are in characters
+		runes := []rune(line)
+		start := len(string(runes[:min(max(p.Column-1, 0), len(runes))]))
+		diagnostics = append(diagnostics, Diagnostic{
+			Range:    Range{Start: position(line, index, start), End: position(line, index, len(line))},
+			Severity: severityError,
+			Source:   "selfup",
+			Message:  p.Message,
+		})
+	}
+
+	return writeMessage(s.out, notification{
+		JSONRPC: "2.0",
+		Method:  "textDocument/publishDiagnostics",
+		Params:  PublishDiagnosticsParams{URI: uri, Diagnostics: diagnostics},
+	})
+}
+
+// Resolves only the given line to avoid running unrelated replacers
+func (s *Server) plan(uri string, index int, line string) (runner.Target, bool, error) {
+	if _, ok := s.annotationOffset(uri, index, line); !ok {
+		return runner.Target{}, false, nil
+	}
+	opts := s.options(uri)
+	// Other definitions are cut off, and the values are kept for `key`
+	document := lines(s.documents[uri])
+	for i, l := range document {
+		if offset, ok := runner.AnnotationOffset(l, opts.Prefix); ok && i != index {
+			document[i] = l[:offset]
+		}
+	}
+	result, err := runner.DryRunWith(strings.NewReader(strings.Join(document, "\n")), opts)
+	if err != nil {
+		return runner.Target{}, true, err
+	}
+	for _, t := range result.Targets {
+		if t.AnnotationLineNumber == index+1 {
+			return t, true, nil
+		}
+	}
+
+	return runner.Target{}, false, nil
+}
+
+// The value can be in another line than the definition with `key`
+func (s *Server) valueRange(uri string, t runner.Target) Range {
+	index := t.LineNumber - 1
+	line, _ := s.line(uri, index)
+
+	return Range{
+		Start: position(line, index, t.ValueBytes.Start),
+		End:   position(line, index, t.ValueBytes.End),
+	}
+}
+
+func (s *Server) hover(params TextDocumentPositionParams) (*Hover, error) {
+	line, ok := s.line(params.TextDocument.URI, params.Position.Line)
+	if !ok {
+		return nil, nil
+	}
+	target, found, err := s.plan(params.TextDocument.URI, params.Position.Line, line)
+	if !found {
+		return nil, nil
+	}
+	if err != nil {
+		return &Hover{Contents: MarkupContent{Kind: "markdown", Value: fmt.Sprintf("Resolving has been failed: %v", err)}}, nil
+	}
+
+	r := s.valueRange(params.TextDocument.URI, target)
+	return &Hover{
+		Contents: MarkupContent{
+			Kind:  "markdown",
+			Value: fmt.Sprintf("**%s**\n\nCurrent: `%s`\n\nResolved: `%s`", target.Name(), target.Extracted, target.Replacer),
+		},
+		Range: &r,
+	}, nil
+}
+
+func (s *Server) codeActions(params CodeActionParams) []CodeAction {
+	actions := []CodeAction{}
+	for index := params.Range.Start.Line; index <= params.Range.End.Line; index++ {
+		line, ok := s.line(params.TextDocument.URI, index)
+		if !ok {
+			break
+		}
+		target, found, err := s.plan(params.TextDocument.URI, index, line)
+		if !found || err != nil || !target.IsChanged {
+			continue
+		}
+		actions = append(actions, CodeAction{
+			Title: fmt.Sprintf("Update %s %s -> %s", target.Name(), target.Extracted, target.Replacer),
+			Kind:  "quickfix",
+			Edit: WorkspaceEdit{
+				Changes: map[string][]TextEdit{
+					params.TextDocument.URI: {{Range: s.valueRange(params.TextDocument.URI, target), NewText: target.Replacer}},
+				},
+			},
+		})
+	}
+
+	return actions
+}
+
+func (s *Server) completion(params TextDocumentPositionParams) (CompletionList, error) {
+	list := CompletionList{Items: []CompletionItem{}}
+	line, ok := s.line(params.TextDocument.URI, params.Position.Line)
+	if !ok {
+		return list, nil
+	}
+	start, ok := s.annotationOffset(params.TextDocument.URI, params.Position.Line, line)
+	if !ok || byteOffset(line, params.Position.Character) <= start {
+		return list, nil
+	}
+
+	definition, err := schema.Generate()
+	if err != nil {
+		return list, err
+	}
+	jsonStr := line[start:]
+	for _, name := range slices.Sorted(maps.Keys(definition.Properties)) {
+		if strings.Contains(jsonStr, `"`+name+`"`) {
+			continue
+		}
+		property := definition.Properties[name]
+		list.Items = append(list.Items, CompletionItem{
+			Label:         name,
+			Kind:          completionItemKindProperty,
+			Detail:        property.Type,
+			Documentation: &MarkupContent{Kind: "plaintext", Value: property.Description},
+		})
+	}
+
+	return list, nil
+}
@@ -0,0 +1,312 @@
+package lsp
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/kachick/selfup/internal/runner"
+)
+
+const defaultPrefix string = "\\s*[#;/]* selfup "
+
+type client struct {
+	t      *testing.T
+	in     io.WriteCloser
+	out    *bufio.Reader
+	nextID int
+	done   chan error
+}
+
+// Starts the server in the same process and talks with pipes like stdio
+func newClient(t *testing.T) *client {
+	t.Helper()
+
+	clientReader, serverWriter := io.Pipe()
+	serverReader, clientWriter := io.Pipe()
+	server := &Server{
+		Options: runner.Options{
+			Prefix: regexp.MustCompile(defaultPrefix),
+			Executor: runner.FakeExecutor{Outputs: map[string]string{
+				"echo 0.40.2": "0.40.2\n",
+				"echo 0.39.0": "0.39.0\n",
+			}},
+		},
+		Version: "test",
+	}
+	c := &client{t: t, in: clientWriter, out: bufio.NewReader(clientReader), done: make(chan error, 1)}
+	go func() {
+		c.done <- server.Serve(serverReader, serverWriter)
+		serverWriter.Close()
+	}()
+
+	return c
+}
+
+func (c *client) send(method string, params any, id *int) {
+	c.t.Helper()
+
+	message := map[string]any{"jsonrpc": "2.0", "method": method, "params": params}
+	if id != nil {
+		message["id"] = *id
+	}
+	err := writeMessage(c.in, message)
+	if err != nil {
+		c.t.Fatalf("unexpected error happened: %v", err)
+	}
+}
+
+func (c *client) receive(v any) {
+	c.t.Helper()
+
+	body, err := readMessage(c.out)
+	if err != nil {
+		c.t.Fatalf("unexpected error happened: %v", err)
+	}
+	err = json.Unmarshal(body, v)
+	if err != nil {
+		c.t.Fatalf("unexpected error happened: %v", err)
+	}
+}
+
+func (c *client) request(method string, params any, result any) {
+	c.t.Helper()
+
+	c.nextID++
+	id := c.nextID
+	c.send(method, params, &id)
+	var res struct {
+		ID     int             `json:"id"`
+		Result json.RawMessage `json:"result"`
+		Error  *responseError  `json:"error"`
+	}
+	c.receive(&res)
+	if res.ID != id {
+		c.t.Fatalf("wrong id: %d", res.ID)
+	}
+	if res.Error != nil {
+		c.t.Fatalf("unexpected error happened: %v", res.Error)
+	}
+	err := json.Unmarshal(res.Result, result)
+	if err != nil {
+		c.t.Fatalf("unexpected error happened: %v", err)
+	}
+}
+
+func (c *client) diagnostics() PublishDiagnosticsParams {
+	c.t.Helper()
+
+	var n struct {
+		Method string                   `json:"method"`
+		Params PublishDiagnosticsParams `json:"params"`
+	}
+	c.receive(&n)
+	if n.Method != "textDocument/publishDiagnostics" {
+		c.t.Fatalf("unexpected notification: %s", n.Method)
+	}
+
+	return n.Params
+}
+
+const uri = "file:///project/.github/workflows/lint.yml"
+
+// The value is after multibyte characters to test UTF-16 positions
+const document = `jobs:
+  名前: '0.39.0' # selfup { "extract": "\\d[^']+", "replacer": ["echo", "0.40.2"] }
+  latest: '0.39.0' # selfup { "extract": "\\d[^']+", "replacer": ["echo", "0.39.0"] }
+  broken: '0.39.0' # selfup { "extract": "\\d[^']+", "replacr": ["echo", "0.39.0"] }
+`
+
+func TestServer(t *testing.T) {
+	c := newClient(t)
+
+	var initialized InitializeResult
+	c.request("initialize", map[string]any{"capabilities": map[string]any{}}, &initialized)
+	if !initialized.Capabilities.HoverProvider || !initialized.Capabilities.CodeActionProvider || initialized.Capabilities.CompletionProvider == nil {
+		t.Errorf("wrong capabilities: %v", initialized.Capabilities)
+	}
+	c.send("initialized", map[string]any{}, nil)
+
+	t.Run("Diagnostics", func(t *testing.T) {
+		c.send("textDocument/didOpen", DidOpenTextDocumentParams{TextDocument: TextDocumentItem{URI: uri, Text: document, Version: 1}}, nil)
+		got := c.diagnostics()
+		want := PublishDiagnosticsParams{
+			URI: uri,
+			Diagnostics: []Diagnostic{
+				{
+					Range:    Range{Start: Position{Line: 3, Character: 53}, End: Position{Line: 3, Character: 84}},
+					Severity: severityError,
+					Source:   "selfup",
+					Message:  "Unknown field `replacr`, did you mean `replacer`?",
+				},
+				{
+					Range:    Range{Start: Position{Line: 3, Character: 28}, End: Position{Line: 3, Character: 84}},
+					Severity: severityError,
+					Source:   "selfup",
+					Message:  "`replacer` is missing",
+				},
+			},
+		}
+		if diff := cmp.Diff(want, got); diff != "" {
+			t.Errorf("wrong result: %s", diff)
+		}
+
+		fixed := strings.Replace(document, "replacr", "replacer", 1)
+		c.send("textDocument/didChange", DidChangeTextDocumentParams{
+			TextDocument:   TextDocumentIdentifier{URI: uri},
+			ContentChanges: []TextDocumentContentChangeEvent{{Text: fixed}},
+		}, nil)
+		if got := c.diagnostics(); len(got.Diagnostics) != 0 {
+			t.Errorf("expected no diagnostics after fixing: %v", got.Diagnostics)
+		}
+	})
+
+	t.Run("Hover", func(t *testing.T) {
+		var got Hover
+		c.request("textDocument/hover", TextDocumentPositionParams{TextDocument: TextDocumentIdentifier{URI: uri}, Position: Position{Line: 1, Character: 8}}, &got)
+		want := Hover{
+			Contents: MarkupContent{Kind: "markdown", Value: "**echo**\n\nCurrent: `0.39.0`\n\nResolved: `0.40.2`"},
+			Range:    &Range{Start: Position{Line: 1, Character: 7}, End: Position{Line: 1, Character: 13}},
+		}
+		if diff := cmp.Diff(want, got); diff != "" {
+			t.Errorf("wrong result: %s", diff)
+		}
+
+		var none *Hover
+		c.request("textDocument/hover", TextDocumentPositionParams{TextDocument: TextDocumentIdentifier{URI: uri}, Position: Position{Line: 0, Character: 1}}, &none)
+		if none != nil {
+			t.Errorf("expected no hover without annotations: %v", none)
+		}
+	})
+
+	t.Run("Hover on key", func(t *testing.T) {
+		keyURI := "file:///project/dprint.toml"
+		c.send("textDocument/didOpen", DidOpenTextDocumentParams{TextDocument: TextDocumentItem{URI: keyURI, Text: "# selfup { \"key\": \"tools.dprint\", \"replacer\": [\"echo\", \"0.40.2\"] }\n[tools]\ndprint = \"0.39.0\"\n", Version: 1}}, nil)
+		if diagnostics := c.diagnostics(); len(diagnostics.Diagnostics) != 0 {
+			t.Errorf("unexpected diagnostics: %v", diagnostics)
+		}
+
+		var got Hover
+		c.request("textDocument/hover", TextDocumentPositionParams{TextDocument: TextDocumentIdentifier{URI: keyURI}, Position: Position{Line: 0, Character: 12}}, &got)
+		want := Hover{
+			Contents: MarkupContent{Kind: "markdown", Value: "**echo**\n\nCurrent: `0.39.0`\n\nResolved: `0.40.2`"},
+			Range:    &Range{Start: Position{Line: 2, Character: 10}, End: Position{Line: 2, Character: 16}},
+		}
+		if diff := cmp.Diff(want, got); diff != "" {
+			t.Errorf("wrong result: %s", diff)
+		}
+	})
+
+	t.Run("CodeAction", func(t *testing.T) {
+		var got []CodeAction
+		c.request("textDocument/codeAction", CodeActionParams{
+			TextDocument: TextDocumentIdentifier{URI: uri},
+			Range:        Range{Start: Position{Line: 0}, End: Position{Line: 3}},
+		}, &got)
+		want := []CodeAction{
+			{
+				Title: "Update echo 0.39.0 -> 0.40.2",
+				Kind:  "quickfix",
+				Edit: WorkspaceEdit{Changes: map[string][]TextEdit{
+					uri: {{Range: Range{Start: Position{Line: 1, Character: 7}, End: Position{Line: 1, Character: 13}}, NewText: "0.40.2"}},
+				}},
+			},
+		}
+		if diff := cmp.Diff(want, got); diff != "" {
+			t.Errorf("wrong result: %s", diff)
+		}
+	})
+
+	t.Run("Completion", func(t *testing.T) {
+		var got CompletionList
+		c.request("textDocument/completion", TextDocumentPositionParams{TextDocument: TextDocumentIdentifier{URI: uri}, Position: Position{Line: 2, Character: 30}}, &got)
+		labels := []string{}
+		for _, item := range got.Items {
+			labels = append(labels, item.Label)
+		}
+		if diff := cmp.Diff([]string{"cwd", "delimiter", "env", "id", "key", "nth"}, labels); diff != "" {
+			t.Errorf("wrong result: %s", diff)
+		}
+
+		var outside CompletionList
+		c.request("textDocument/completion", TextDocumentPositionParams{TextDocument: TextDocumentIdentifier{URI: uri}, Position: Position{Line: 2, Character: 4}}, &outside)
+		if len(outside.Items) != 0 {
+			t.Errorf("expected no completion outside of the JSON: %v", outside.Items)
+		}
+	})
+
+	var shutdown any
+	c.request("shutdown", nil, &shutdown)
+	c.send("exit", nil, nil)
+	err := <-c.done
+	if err != nil {
+		t.Fatalf("unexpected error happened: %v", err)
+	}
+}
+
+func TestUnsupportedMethod(t *testing.T) {
+	c := newClient(t)
+
+	id := 1
+	c.send("workspace/symbol", map[string]any{"query": ""}, &id)
+	var res struct {
+		Error *responseError `json:"error"`
+	}
+	c.receive(&res)
+	if res.Error == nil || res.Error.Code != codeMethodNotFound {
+		t.Errorf("wrong error: %v", res.Error)
+	}
+
+	c.in.Close()
+	err := <-c.done
+	if err != nil {
+		t.Fatalf("unexpected error happened: %v", err)
+	}
+}
+
+func TestReadMessage(t *testing.T) {
+	testCases := map[string]struct {
+		input string
+		want  string
+		err   string
+	}{
+		"Valid": {
+			input: "Content-Length: 2\r\n\r\n{}",
+			want:  "{}",
+		},
+		"Negative length": {
+			input: "Content-Length: -1\r\n\r\n{}",
+			err:   "out of range",
+		},
+		"Too large length": {
+			input: "Content-Length: 1073741824\r\n\r\n{}",
+			err:   "out of range",
+		},
+		"Not a number": {
+			input: "Content-Length: two\r\n\r\n{}",
+			err:   "Invalid Content-Length header",
+		},
+	}
+
+	for what, tc := range testCases {
+		t.Run(what, func(t *testing.T) {
+			body, err := readMessage(bufio.NewReader(strings.NewReader(tc.input)))
+			if tc.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tc.err) {
+					t.Errorf("expected an error with %q, but got %v", tc.err, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error happened: %v", err)
+			}
+			if diff := cmp.Diff(tc.want, string(body)); diff != "" {
+				t.Errorf("wrong result: (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
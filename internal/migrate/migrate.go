@@ -8,6 +8,9 @@ import (
 	"strings"
 )
 
+// Version of the definitions written by Migrate, they are also valid in the later versions
+const Version = "v1"
+
 type V1Schema struct {
 	Extract   string   `json:"extract"`
 	Command   []string `json:"replacer"`
@@ -0,0 +1,186 @@
+package policy
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/kachick/selfup/internal/runner"
+	"golang.org/x/xerrors"
+)
+
+// Rule is an argv pattern. Each element is a glob that "*" matches any characters including "/".
+// "**" as the last element matches any remaining arguments.
+type Rule []string
+
+// Like path.Match, but "*" also matches "/" because arguments are not always paths
+func matchGlob(pattern string, s string) bool {
+	if pattern == "" {
+		return s == ""
+	}
+	switch pattern[0] {
+	case '*':
+		for i := 0; i <= len(s); i++ {
+			if matchGlob(pattern[1:], s[i:]) {
+				return true
+			}
+		}
+		return false
+	case '?':
+		return s != "" && matchGlob(pattern[1:], s[1:])
+	default:
+		return s != "" && s[0] == pattern[0] && matchGlob(pattern[1:], s[1:])
+	}
+}
+
+func (r Rule) Matches(argv []string) bool {
+	for i, pattern := range r {
+		if pattern == "**" && i == len(r)-1 {
+			return true
+		}
+		if i >= len(argv) || !matchGlob(pattern, argv[i]) {
+			return false
+		}
+	}
+
+	return len(r) == len(argv)
+}
+
+type Policy struct {
+	Allow []Rule `json:"allow"`
+}
+
+func (p Policy) Allows(argv []string) bool {
+	for _, rule := range p.Allow {
+		if rule.Matches(argv) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Prompt asks whether the unknown command can be executed
+type Prompt func(cmd runner.Command) (bool, error)
+
+// TerminalPrompt asks in the terminal, only "y" and "yes" approve the command
+func TerminalPrompt(in io.Reader, out io.Writer) Prompt {
+	reader := bufio.NewReader(in)
+	return func(cmd runner.Command) (bool, error) {
+		fmt.Fprintf(out, "`%s` is not allowed by the policy. Execute it? [y/N] ", describe(cmd))
+		answer, err := reader.ReadString('\n')
+		if err != nil && err != io.EOF {
+			return false, err
+		}
+		answer = strings.ToLower(strings.TrimSpace(answer))
+
+		return answer == "y" || answer == "yes", nil
+	}
+}
+
+// Executor checks commands with the policy before delegating to Next
+type Executor struct {
+	// Defaults to runner.ExecExecutor
+	Next   runner.Executor
+	Policy Policy
+	// Executes unknown commands without asking
+	Trust bool
+	// Asked for unknown commands if not trusted, denies them if nil
+	Prompt Prompt
+
+	mu       sync.Mutex
+	approved map[string]bool
+}
+
+// Returns the names of the variables that differ from the environment of selfup, they can inject code like BASH_ENV and LD_PRELOAD
+func overridden(env []string, environ []string) []string {
+	names := []string{}
+	for _, kv := range env {
+		if !slices.Contains(environ, kv) {
+			name, _, _ := strings.Cut(kv, "=")
+			names = append(names, name)
+		}
+	}
+
+	return names
+}
+
+// Describes the command with the cwd and the overridden variables, because rules only match the argv
+func describe(cmd runner.Command) string {
+	s := cmd.String()
+	if cmd.Dir != "" {
+		s += " in " + cmd.Dir
+	}
+	if names := overridden(cmd.Env, os.Environ()); len(names) > 0 {
+		s += " with " + strings.Join(names, ", ")
+	}
+
+	return s
+}
+
+// Allowed commands are still denied with the cwd or the overridden variables
+func (p Policy) allowsCommand(cmd runner.Command) bool {
+	return p.Allows(cmd.Argv) && cmd.Dir == "" && len(overridden(cmd.Env, os.Environ())) == 0
+}
+
+func (e *Executor) check(cmd runner.Command) error {
+	if e.Trust || e.Policy.allowsCommand(cmd) {
+		return nil
+	}
+
+	// Prompts should not be mixed in concurrent runs
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	key := strings.Join(append(append([]string{cmd.Dir}, cmd.Env...), cmd.Argv...), "\x00")
+	if approved, ok := e.approved[key]; ok {
+		if approved {
+			return nil
+		}
+		return xerrors.Errorf("`%s` is denied", describe(cmd))
+	}
+	if e.Prompt == nil {
+		if e.Policy.Allows(cmd.Argv) {
+			return xerrors.Errorf("`%s` is not allowed by the policy because of the cwd or env, use --trust", describe(cmd))
+		}
+		return xerrors.Errorf("`%s` is not allowed by the policy, add it into the config or use --trust", cmd)
+	}
+	approved, err := e.Prompt(cmd)
+	if err != nil {
+		return err
+	}
+	if e.approved == nil {
+		e.approved = map[string]bool{}
+	}
+	e.approved[key] = approved
+	if !approved {
+		return xerrors.Errorf("`%s` is denied", describe(cmd))
+	}
+
+	return nil
+}
+
+func (e *Executor) Execute(cmd runner.Command) ([]byte, error) {
+	err := e.check(cmd)
+	if err != nil {
+		return nil, err
+	}
+
+	next := e.Next
+	if next == nil {
+		next = runner.ExecExecutor{}
+	}
+
+	return next.Execute(cmd)
+}
+
+// NoExecExecutor refuses all commands, so only built-in resolvers like lock files can be used
+type NoExecExecutor struct{}
+
+func (NoExecExecutor) Execute(cmd runner.Command) ([]byte, error) {
+	return nil, xerrors.Errorf("`%s` is not executed in no-exec mode, use built-in resolvers like --locked", cmd)
+}
@@ -0,0 +1,169 @@
+package policy
+
+import (
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/kachick/selfup/internal/runner"
+)
+
+func TestRuleMatches(t *testing.T) {
+	type testCase struct {
+		rule Rule
+		argv []string
+		want bool
+	}
+	testCases := map[string]testCase{
+		"Exact": {
+			rule: Rule{"dprint", "--version"},
+			argv: []string{"dprint", "--version"},
+			want: true,
+		},
+		"Extra arguments": {
+			rule: Rule{"dprint", "--version"},
+			argv: []string{"dprint", "--version", "--verbose"},
+			want: false,
+		},
+		"Missing arguments": {
+			rule: Rule{"dprint", "--version"},
+			argv: []string{"dprint"},
+			want: false,
+		},
+		"Any remaining arguments": {
+			rule: Rule{"nix", "eval", "**"},
+			argv: []string{"nix", "eval", "--raw", "nixpkgs#dprint.version"},
+			want: true,
+		},
+		"Glob including slashes": {
+			rule: Rule{"nix", "eval", "--raw", "github:NixOS/nixpkgs/*#*.version"},
+			argv: []string{"nix", "eval", "--raw", "github:NixOS/nixpkgs/nixos-unstable#dprint.version"},
+			want: true,
+		},
+		"Executable only": {
+			rule: Rule{"echo", "**"},
+			argv: []string{"echo"},
+			want: true,
+		},
+		"Different executable": {
+			rule: Rule{"echo", "**"},
+			argv: []string{"bash", "-c", "echo"},
+			want: false,
+		},
+	}
+
+	for what, tc := range testCases {
+		t.Run(what, func(t *testing.T) {
+			if got := tc.rule.Matches(tc.argv); got != tc.want {
+				t.Errorf("expected %v, got %v", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestExecutor(t *testing.T) {
+	fake := runner.FakeExecutor{Outputs: map[string]string{
+		"dprint --version":  "dprint 0.40.2",
+		"curl evil.example": "0.0.0",
+	}}
+	p := Policy{Allow: []Rule{{"dprint", "--version"}}}
+
+	t.Run("Allowed", func(t *testing.T) {
+		executor := &Executor{Next: fake, Policy: p}
+		out, err := executor.Execute(runner.Command{Argv: []string{"dprint", "--version"}})
+		if err != nil {
+			t.Fatalf("unexpected error happened: %v", err)
+		}
+		if string(out) != "dprint 0.40.2" {
+			t.Errorf("wrong output: %s", out)
+		}
+	})
+
+	t.Run("Denied", func(t *testing.T) {
+		recorder := &runner.RecordingExecutor{Next: fake}
+		executor := &Executor{Next: recorder, Policy: p}
+		_, err := executor.Execute(runner.Command{Argv: []string{"curl", "evil.example"}})
+		if err == nil {
+			t.Fatalf("expected error did not happen")
+		}
+		if len(recorder.Commands()) != 0 {
+			t.Errorf("denied command has been executed: %v", recorder.Commands())
+		}
+	})
+
+	t.Run("Allowed argv with cwd or env", func(t *testing.T) {
+		for _, cmd := range []runner.Command{
+			{Argv: []string{"dprint", "--version"}, Env: append(os.Environ(), "BASH_ENV=./evil.sh")},
+			{Argv: []string{"dprint", "--version"}, Env: append(os.Environ(), "LD_PRELOAD=evil.so")},
+			{Argv: []string{"dprint", "--version"}, Dir: "scripts"},
+		} {
+			recorder := &runner.RecordingExecutor{Next: fake}
+			executor := &Executor{Next: recorder, Policy: p}
+			_, err := executor.Execute(cmd)
+			if err == nil || !strings.Contains(err.Error(), "because of the cwd or env") {
+				t.Errorf("expected error did not happen: %v", err)
+			}
+			if len(recorder.Commands()) != 0 {
+				t.Errorf("denied command has been executed: %v", recorder.Commands())
+			}
+		}
+
+		// Restricting the environment does not inject anything
+		executor := &Executor{Next: fake, Policy: p}
+		_, err := executor.Execute(runner.Command{Argv: []string{"dprint", "--version"}, Env: []string{}})
+		if err != nil {
+			t.Errorf("unexpected error happened: %v", err)
+		}
+	})
+
+	t.Run("Trusted", func(t *testing.T) {
+		executor := &Executor{Next: fake, Policy: p, Trust: true}
+		_, err := executor.Execute(runner.Command{Argv: []string{"curl", "evil.example"}})
+		if err != nil {
+			t.Fatalf("unexpected error happened: %v", err)
+		}
+	})
+
+	t.Run("Prompt", func(t *testing.T) {
+		asked := []string{}
+		prompt := func(cmd runner.Command) (bool, error) {
+			asked = append(asked, cmd.String())
+			return cmd.Argv[0] == "curl", nil
+		}
+		executor := &Executor{Next: fake, Policy: p, Prompt: prompt}
+		for range 2 {
+			_, err := executor.Execute(runner.Command{Argv: []string{"curl", "evil.example"}})
+			if err != nil {
+				t.Fatalf("unexpected error happened: %v", err)
+			}
+		}
+		if diff := cmp.Diff([]string{"curl evil.example"}, asked); diff != "" {
+			t.Errorf("the approval should be remembered: %s", diff)
+		}
+	})
+}
+
+func TestTerminalPrompt(t *testing.T) {
+	out := new(strings.Builder)
+	prompt := TerminalPrompt(strings.NewReader("y\nno\n"), out)
+
+	approved, err := prompt(runner.Command{Argv: []string{"curl", "evil.example"}})
+	if err != nil || !approved {
+		t.Errorf("expected approved, got %v, %v", approved, err)
+	}
+	approved, err = prompt(runner.Command{Argv: []string{"curl", "evil.example"}})
+	if err != nil || approved {
+		t.Errorf("expected denied, got %v, %v", approved, err)
+	}
+	if !strings.Contains(out.String(), "`curl evil.example` is not allowed by the policy") {
+		t.Errorf("wrong prompt: %s", out.String())
+	}
+}
+
+func TestNoExecExecutor(t *testing.T) {
+	_, err := NoExecExecutor{}.Execute(runner.Command{Argv: []string{"echo", "42"}})
+	if err == nil {
+		t.Fatalf("expected error did not happen")
+	}
+}
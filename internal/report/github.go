@@ -0,0 +1,65 @@
+package report
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/kachick/selfup/internal/runner"
+	"golang.org/x/xerrors"
+)
+
+// https://github.com/actions/toolkit/blob/main/packages/core/src/command.ts
+func escapeData(s string) string {
+	return strings.NewReplacer("%", "%25", "\r", "%0D", "\n", "%0A").Replace(s)
+}
+
+func escapeProperty(s string) string {
+	return strings.NewReplacer("%", "%25", "\r", "%0D", "\n", "%0A", ":", "%3A", ",", "%2C").Replace(s)
+}
+
+// GitHub renders workflow commands to annotate the changes and errors in PRs.
+// The changes are warnings in dry runs, and notices if they have been applied.
+func GitHub(files []File, isApplied bool) string {
+	b := new(strings.Builder)
+	for _, f := range files {
+		if f.Err != nil {
+			properties := "file=" + escapeProperty(f.Path)
+			if line, ok := ErrorLine(f.Err); ok {
+				properties += fmt.Sprintf(",line=%d", line)
+			}
+			fmt.Fprintf(b, "::error %s,title=selfup::%s\n", properties, escapeData(ErrorMessage(f.Err)))
+		}
+	}
+	for _, c := range Changes(files) {
+		properties := fmt.Sprintf("file=%s,line=%d,col=%d,endColumn=%d,title=selfup", escapeProperty(c.Path), c.LineNumber, c.Column, c.EndColumn)
+		if isApplied {
+			fmt.Fprintf(b, "::notice %s::%s\n", properties, escapeData(fmt.Sprintf("%s %s has been updated to %s", c.Name, c.From, c.To)))
+		} else {
+			fmt.Fprintf(b, "::warning %s::%s\n", properties, escapeData(fmt.Sprintf("%s %s is outdated (%s)", c.Name, c.From, c.To)))
+		}
+	}
+
+	return b.String()
+}
+
+// GitHubProblem renders an error annotation for the problem found in validation
+func GitHubProblem(path string, p runner.Problem) string {
+	return fmt.Sprintf("::error file=%s,line=%d,col=%d,title=selfup::%s\n", escapeProperty(path), p.Line, p.Column, escapeData(p.Message))
+}
+
+// WriteStepSummary appends the Markdown report to the job summary, typically given in $GITHUB_STEP_SUMMARY
+func WriteStepSummary(path string, files []File) error {
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return xerrors.Errorf("Opening the step summary has been failed: %w", err)
+	}
+	defer file.Close()
+
+	_, err = file.WriteString(Markdown(files))
+	if err != nil {
+		return xerrors.Errorf("Writing the step summary has been failed: %w", err)
+	}
+
+	return nil
+}
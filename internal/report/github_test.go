@@ -0,0 +1,79 @@
+package report
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/kachick/selfup/internal/runner"
+	"golang.org/x/xerrors"
+)
+
+func TestGitHub(t *testing.T) {
+	withErrors := []File{
+		files[0],
+		{
+			Path: "broken,name.yml",
+			Err: &runner.LineError{
+				Line: 3,
+				Err: xerrors.Errorf("Executing nix has been failed: %w", &runner.CommandError{
+					Argv:     []string{"nix", "eval"},
+					ExitCode: 1,
+					Duration: time.Second,
+					Stderr:   "error: 100% broken\n",
+					Err:      xerrors.New("exit status 1"),
+				}),
+			},
+		},
+		{
+			Path: "missing.yml",
+			Err:  xerrors.New("open missing.yml: no such file or directory"),
+		},
+	}
+
+	t.Run("List", func(t *testing.T) {
+		want := "::error file=broken%2Cname.yml,line=3,title=selfup::Executing nix has been failed: `nix eval` exited with 1 after 1s: exit status 1%0ASTDERR:%0Aerror: 100%25 broken\n" +
+			"::error file=missing.yml,title=selfup::open missing.yml: no such file or directory\n" +
+			"::warning file=.github/workflows/lint.yml,line=17,col=27,endColumn=33,title=selfup::dprint 0.39.0 is outdated (0.40.2)\n"
+		if diff := cmp.Diff(want, GitHub(withErrors, false)); diff != "" {
+			t.Errorf("wrong result: %s", diff)
+		}
+	})
+
+	t.Run("Run", func(t *testing.T) {
+		want := "::notice file=.github/workflows/lint.yml,line=17,col=27,endColumn=33,title=selfup::dprint 0.39.0 has been updated to 0.40.2\n"
+		if diff := cmp.Diff(want, GitHub(files[:1], true)); diff != "" {
+			t.Errorf("wrong result: %s", diff)
+		}
+	})
+}
+
+func TestGitHubProblem(t *testing.T) {
+	got := GitHubProblem("lint.yml", runner.Problem{Line: 17, Column: 50, Message: "Unknown field `replacr`, did you mean `replacer`?"})
+	want := "::error file=lint.yml,line=17,col=50,title=selfup::Unknown field `replacr`, did you mean `replacer`?\n"
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("wrong result: %s", diff)
+	}
+}
+
+func TestWriteStepSummary(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "summary.md")
+	err := os.WriteFile(path, []byte("# Previous step\n"), 0o644)
+	if err != nil {
+		t.Fatalf("unexpected error happened: %v", err)
+	}
+
+	err = WriteStepSummary(path, files)
+	if err != nil {
+		t.Fatalf("unexpected error happened: %v", err)
+	}
+	got, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("unexpected error happened: %v", err)
+	}
+	if diff := cmp.Diff("# Previous step\n"+Markdown(files), string(got)); diff != "" {
+		t.Errorf("wrong result: %s", diff)
+	}
+}
@@ -0,0 +1,121 @@
+package report
+
+import (
+	"encoding/xml"
+	"fmt"
+	"strings"
+
+	"golang.org/x/xerrors"
+)
+
+// https://github.com/testmoapp/junitxml
+type junitTestSuites struct {
+	XMLName    xml.Name         `xml:"testsuites"`
+	Name       string           `xml:"name,attr"`
+	Tests      int              `xml:"tests,attr"`
+	Failures   int              `xml:"failures,attr"`
+	Skipped    int              `xml:"skipped,attr"`
+	TestSuites []junitTestSuite `xml:"testsuite"`
+}
+
+type junitTestSuite struct {
+	Name      string          `xml:"name,attr"`
+	Tests     int             `xml:"tests,attr"`
+	Failures  int             `xml:"failures,attr"`
+	Skipped   int             `xml:"skipped,attr"`
+	TestCases []junitTestCase `xml:"testcase"`
+}
+
+type junitTestCase struct {
+	Name      string        `xml:"name,attr"`
+	ClassName string        `xml:"classname,attr"`
+	File      string        `xml:"file,attr"`
+	Line      int           `xml:"line,attr,omitempty"`
+	Failure   *junitFailure `xml:"failure"`
+	Skipped   *junitSkipped `xml:"skipped"`
+}
+
+type junitSkipped struct {
+	Message string `xml:"message,attr"`
+}
+
+type junitFailure struct {
+	Message string `xml:"message,attr"`
+	Type    string `xml:"type,attr"`
+	Text    string `xml:",chardata"`
+}
+
+// JUnit renders each file as a testsuite and each definition as a testcase.
+// Outdated values fail only in the check mode, and errors always fail.
+func JUnit(files []File, isCheck bool) ([]byte, error) {
+	suites := junitTestSuites{Name: "selfup", TestSuites: []junitTestSuite{}}
+	for _, f := range files {
+		suite := junitTestSuite{Name: f.Path, TestCases: []junitTestCase{}}
+		if f.Err != nil {
+			line, _ := ErrorLine(f.Err)
+			text := ErrorMessage(f.Err)
+			message, _, _ := strings.Cut(text, "\n")
+			name := f.Path
+			if line > 0 {
+				name = fmt.Sprintf("%s:%d", f.Path, line)
+			}
+			suite.TestCases = append(suite.TestCases, junitTestCase{
+				Name:      name,
+				ClassName: f.Path,
+				File:      f.Path,
+				Line:      line,
+				Failure: &junitFailure{
+					Message: message,
+					Type:    ErrorRule(f.Err),
+					Text:    text,
+				},
+			})
+		}
+		for _, t := range f.Result.Targets {
+			testCase := junitTestCase{
+				Name:      fmt.Sprintf("%s:%d:%d %s", f.Path, t.LineNumber, t.ValueRunes.Start+1, t.Name()),
+				ClassName: f.Path,
+				File:      f.Path,
+				Line:      t.LineNumber,
+			}
+			if t.IsChanged && isCheck {
+				message := fmt.Sprintf("%s %s is outdated (%s)", t.Name(), t.Extracted, t.Replacer)
+				testCase.Failure = &junitFailure{
+					Message: message,
+					Type:    RuleOutdatedValue,
+					Text:    message,
+				}
+			}
+			suite.TestCases = append(suite.TestCases, testCase)
+		}
+		for _, s := range f.Result.Skipped {
+			suite.TestCases = append(suite.TestCases, junitTestCase{
+				Name:      fmt.Sprintf("%s:%d", f.Path, s.LineNumber),
+				ClassName: f.Path,
+				File:      f.Path,
+				Line:      s.LineNumber,
+				Skipped:   &junitSkipped{Message: "skipped by " + s.Directive},
+			})
+		}
+		for _, c := range suite.TestCases {
+			suite.Tests++
+			if c.Failure != nil {
+				suite.Failures++
+			}
+			if c.Skipped != nil {
+				suite.Skipped++
+			}
+		}
+		suites.Tests += suite.Tests
+		suites.Failures += suite.Failures
+		suites.Skipped += suite.Skipped
+		suites.TestSuites = append(suites.TestSuites, suite)
+	}
+
+	bytes, err := xml.MarshalIndent(suites, "", "  ")
+	if err != nil {
+		return nil, xerrors.Errorf("Marshaling JUnit XML has been failed: %w", err)
+	}
+
+	return append([]byte(xml.Header), append(bytes, '\n')...), nil
+}
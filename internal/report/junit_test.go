@@ -0,0 +1,67 @@
+package report
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/kachick/selfup/internal/runner"
+	"golang.org/x/xerrors"
+)
+
+func TestJUnit(t *testing.T) {
+	withErrors := []File{
+		files[0],
+		{
+			Path: "broken.yml",
+			Err:  &runner.LineError{Line: 3, Err: xerrors.New("Invalid regex `[`")},
+		},
+	}
+
+	t.Run("Check", func(t *testing.T) {
+		got, err := JUnit(withErrors, true)
+		if err != nil {
+			t.Fatalf("unexpected error happened: %v", err)
+		}
+		want := `<?xml version="1.0" encoding="UTF-8"?>
+<testsuites name="selfup" tests="3" failures="2" skipped="0">
+  <testsuite name=".github/workflows/lint.yml" tests="2" failures="1" skipped="0">
+    <testcase name=".github/workflows/lint.yml:17:27 dprint" classname=".github/workflows/lint.yml" file=".github/workflows/lint.yml" line="17">
+      <failure message="dprint 0.39.0 is outdated (0.40.2)" type="outdated-value">dprint 0.39.0 is outdated (0.40.2)</failure>
+    </testcase>
+    <testcase name=".github/workflows/lint.yml:30:21 typos" classname=".github/workflows/lint.yml" file=".github/workflows/lint.yml" line="30"></testcase>
+  </testsuite>
+  <testsuite name="broken.yml" tests="1" failures="1" skipped="0">
+    <testcase name="broken.yml:3" classname="broken.yml" file="broken.yml" line="3">
+      <failure message="Invalid regex ` + "`[`" + `" type="invalid-definition">Invalid regex ` + "`[`" + `</failure>
+    </testcase>
+  </testsuite>
+</testsuites>
+`
+		if diff := cmp.Diff(want, string(got)); diff != "" {
+			t.Errorf("wrong result: %s", diff)
+		}
+	})
+
+	t.Run("Outdated values pass without check", func(t *testing.T) {
+		withSkipped := []File{files[0]}
+		withSkipped[0].Result.Skipped = []runner.Skipped{{LineNumber: 40, Directive: runner.DirectiveIgnore}}
+		got, err := JUnit(withSkipped, false)
+		if err != nil {
+			t.Fatalf("unexpected error happened: %v", err)
+		}
+		want := `<?xml version="1.0" encoding="UTF-8"?>
+<testsuites name="selfup" tests="3" failures="0" skipped="1">
+  <testsuite name=".github/workflows/lint.yml" tests="3" failures="0" skipped="1">
+    <testcase name=".github/workflows/lint.yml:17:27 dprint" classname=".github/workflows/lint.yml" file=".github/workflows/lint.yml" line="17"></testcase>
+    <testcase name=".github/workflows/lint.yml:30:21 typos" classname=".github/workflows/lint.yml" file=".github/workflows/lint.yml" line="30"></testcase>
+    <testcase name=".github/workflows/lint.yml:40" classname=".github/workflows/lint.yml" file=".github/workflows/lint.yml" line="40">
+      <skipped message="skipped by selfup-ignore"></skipped>
+    </testcase>
+  </testsuite>
+</testsuites>
+`
+		if diff := cmp.Diff(want, string(got)); diff != "" {
+			t.Errorf("wrong result: %s", diff)
+		}
+	})
+}
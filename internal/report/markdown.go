@@ -0,0 +1,60 @@
+package report
+
+import (
+	"fmt"
+	"strings"
+)
+
+func escapeCell(s string) string {
+	return strings.ReplaceAll(s, "|", "\\|")
+}
+
+func code(s string) string {
+	if s == "" {
+		return ""
+	}
+	delimiter := "`"
+	for strings.Contains(s, delimiter) {
+		delimiter += "`"
+	}
+	if strings.HasPrefix(s, "`") || strings.HasSuffix(s, "`") {
+		return delimiter + " " + s + " " + delimiter
+	}
+
+	return delimiter + s + delimiter
+}
+
+// Markdown renders the changed targets as tables grouped by the replacer, for PR bodies
+func Markdown(files []File) string {
+	changes := Changes(files)
+	if len(changes) == 0 {
+		return "No changes\n"
+	}
+
+	groups := map[string][]Change{}
+	order := []string{}
+	for _, c := range changes {
+		command := strings.Join(c.Command, " ")
+		if _, ok := groups[command]; !ok {
+			order = append(order, command)
+		}
+		groups[command] = append(groups[command], c)
+	}
+
+	b := new(strings.Builder)
+	for i, command := range order {
+		if i > 0 {
+			b.WriteString("\n")
+		}
+		group := groups[command]
+		fmt.Fprintf(b, "### %s\n\n", group[0].Name)
+		fmt.Fprintf(b, "Command: %s\n\n", code(command))
+		b.WriteString("| File | Line | Old | New |\n")
+		b.WriteString("| ---- | ---- | --- | --- |\n")
+		for _, c := range group {
+			fmt.Fprintf(b, "| %s | %d:%d | %s | %s |\n", escapeCell(c.Path), c.LineNumber, c.Column, escapeCell(code(c.From)), escapeCell(code(c.To)))
+		}
+	}
+
+	return b.String()
+}
@@ -0,0 +1,46 @@
+package report
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/kachick/selfup/internal/runner"
+)
+
+func TestMarkdown(t *testing.T) {
+	grouped := slices.Concat(files, []File{{
+		Path: "examples/pipe|name.yml",
+		Result: runner.Result{
+			Targets: []runner.Target{
+				{LineNumber: 5, Extracted: "0.39.0", Replacer: "0.40.2", IsChanged: true, Command: []string{"dprint", "--version"}, ValueRunes: runner.Span{Start: 22, End: 28}},
+			},
+			ChangedCount: 1,
+			Total:        1,
+		},
+	}})
+
+	want := "### dprint\n" +
+		"\n" +
+		"Command: `dprint --version`\n" +
+		"\n" +
+		"| File | Line | Old | New |\n" +
+		"| ---- | ---- | --- | --- |\n" +
+		"| .github/workflows/lint.yml | 17:27 | `0.39.0` | `0.40.2` |\n" +
+		"| examples/pipe\\|name.yml | 5:23 | `0.39.0` | `0.40.2` |\n" +
+		"\n" +
+		"### goreleaser\n" +
+		"\n" +
+		"Command: `bash -c goreleaser --version | grep GitVersion`\n" +
+		"\n" +
+		"| File | Line | Old | New |\n" +
+		"| ---- | ---- | --- | --- |\n" +
+		"| .github/workflows/release.yml | 37:21 | `1.20.0` | `1.42.9` |\n"
+	if diff := cmp.Diff(want, Markdown(grouped)); diff != "" {
+		t.Errorf("wrong result: %s", diff)
+	}
+
+	if diff := cmp.Diff("No changes\n", Markdown(files[2:])); diff != "" {
+		t.Errorf("wrong result without changes: %s", diff)
+	}
+}
@@ -0,0 +1,105 @@
+package report
+
+import (
+	"path/filepath"
+	"strings"
+	"text/template"
+
+	"github.com/kachick/selfup/internal/runner"
+	"golang.org/x/xerrors"
+)
+
+// File is the result of a file
+type File struct {
+	Path   string
+	Result runner.Result
+	Err    error
+}
+
+// Change is a replaced or replaceable target
+type Change struct {
+	Path       string
+	File       string
+	LineNumber int
+	// 1-based columns of the old value in characters, the end is exclusive
+	Column    int
+	EndColumn int
+	Name      string
+	From      string
+	To        string
+	Command   []string
+}
+
+// Changes collects the changed targets in the order of files
+func Changes(files []File) []Change {
+	changes := []Change{}
+	for _, f := range files {
+		if f.Err != nil {
+			continue
+		}
+		for _, t := range f.Result.Targets {
+			if !t.IsChanged {
+				continue
+			}
+			changes = append(changes, Change{
+				Path:       f.Path,
+				File:       filepath.Base(f.Path),
+				LineNumber: t.LineNumber,
+				Column:     t.ValueRunes.Start + 1,
+				EndColumn:  t.ValueRunes.End + 1,
+				Name:       t.Name(),
+				From:       t.Extracted,
+				To:         t.Replacer,
+				Command:    t.Command,
+			})
+		}
+	}
+
+	return changes
+}
+
+// ErrorLine returns the line number of the definition if the error has it
+func ErrorLine(err error) (int, bool) {
+	var lineErr *runner.LineError
+	if xerrors.As(err, &lineErr) {
+		return lineErr.Line, true
+	}
+
+	return 0, false
+}
+
+// ErrorMessage is a message including STDERR of the failed command
+func ErrorMessage(err error) string {
+	message := err.Error()
+	var lineErr *runner.LineError
+	if xerrors.As(err, &lineErr) {
+		message = lineErr.Err.Error()
+	}
+	var cmdErr *runner.CommandError
+	if xerrors.As(err, &cmdErr) && cmdErr.Stderr != "" {
+		message += "\nSTDERR:\n" + strings.TrimSuffix(cmdErr.Stderr, "\n")
+	}
+
+	return message
+}
+
+const DefaultCommitMessage = `{{if eq (len .Changes) 1}}{{with index .Changes 0}}Update {{.Name}} {{.From}} -> {{.To}} in {{.File}}{{end}}
+{{- else}}Update {{len .Changes}} items with selfup
+
+{{range .Changes}}- Update {{.Name}} {{.From}} -> {{.To}} in {{.File}}
+{{end}}{{end}}`
+
+// CommitMessage renders the text/template with the changes as .Changes
+func CommitMessage(tmpl string, files []File) (string, error) {
+	parsed, err := template.New("commit").Parse(tmpl)
+	if err != nil {
+		return "", xerrors.Errorf("Invalid commit message template: %w", err)
+	}
+	message := new(strings.Builder)
+	err = parsed.Execute(message, struct{ Changes []Change }{Changes(files)})
+	if err != nil {
+		return "", xerrors.Errorf("Rendering commit message has been failed: %w", err)
+	}
+
+	return strings.TrimSpace(message.String()) + "\n", nil
+}
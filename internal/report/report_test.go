@@ -0,0 +1,81 @@
+package report
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/kachick/selfup/internal/runner"
+	"golang.org/x/xerrors"
+)
+
+var files = []File{
+	{
+		Path: ".github/workflows/lint.yml",
+		Result: runner.Result{
+			Targets: []runner.Target{
+				{LineNumber: 17, Extracted: "0.39.0", Replacer: "0.40.2", IsChanged: true, Command: []string{"dprint", "--version"}, ValueRunes: runner.Span{Start: 26, End: 32}},
+				{LineNumber: 30, Extracted: "1.10.9", Replacer: "1.10.9", Command: []string{"typos", "--version"}, ValueRunes: runner.Span{Start: 20, End: 26}},
+			},
+			ChangedCount: 1,
+			Total:        2,
+		},
+	},
+	{
+		Path: ".github/workflows/release.yml",
+		Result: runner.Result{
+			Targets: []runner.Target{
+				{LineNumber: 37, Extracted: "1.20.0", Replacer: "1.42.9", IsChanged: true, ID: "goreleaser", Command: []string{"bash", "-c", "goreleaser --version | grep GitVersion"}, ValueRunes: runner.Span{Start: 20, End: 26}},
+			},
+			ChangedCount: 1,
+			Total:        1,
+		},
+	},
+	{
+		Path: "broken.yml",
+		Err:  xerrors.New("3: Invalid regex"),
+	},
+}
+
+func TestCommitMessage(t *testing.T) {
+	t.Run("Single change", func(t *testing.T) {
+		message, err := CommitMessage(DefaultCommitMessage, files[:1])
+		if err != nil {
+			t.Fatalf("unexpected error happened: %v", err)
+		}
+		if diff := cmp.Diff("Update dprint 0.39.0 -> 0.40.2 in lint.yml\n", message); diff != "" {
+			t.Errorf("wrong message: %s", diff)
+		}
+	})
+
+	t.Run("Multiple changes", func(t *testing.T) {
+		message, err := CommitMessage(DefaultCommitMessage, files)
+		if err != nil {
+			t.Fatalf("unexpected error happened: %v", err)
+		}
+		want := `Update 2 items with selfup
+
+- Update dprint 0.39.0 -> 0.40.2 in lint.yml
+- Update goreleaser 1.20.0 -> 1.42.9 in release.yml
+`
+		if diff := cmp.Diff(want, message); diff != "" {
+			t.Errorf("wrong message: %s", diff)
+		}
+	})
+
+	t.Run("Custom template", func(t *testing.T) {
+		message, err := CommitMessage(`Sync CI dependencies with nixpkgs ({{len .Changes}})`, files)
+		if err != nil {
+			t.Fatalf("unexpected error happened: %v", err)
+		}
+		if diff := cmp.Diff("Sync CI dependencies with nixpkgs (2)\n", message); diff != "" {
+			t.Errorf("wrong message: %s", diff)
+		}
+	})
+
+	t.Run("Invalid template", func(t *testing.T) {
+		_, err := CommitMessage(`{{.Unknown`, files)
+		if err == nil {
+			t.Fatalf("expected error did not happen")
+		}
+	})
+}
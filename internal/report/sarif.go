@@ -0,0 +1,227 @@
+package report
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+
+	"github.com/kachick/selfup/internal/runner"
+	"golang.org/x/xerrors"
+)
+
+// https://docs.oasis-open.org/sarif/sarif/v2.1.0/sarif-v2.1.0.html
+const (
+	sarifSchema  = "https://json.schemastore.org/sarif-2.1.0.json"
+	sarifVersion = "2.1.0"
+)
+
+type sarifLog struct {
+	Schema  string     `json:"$schema"`
+	Version string     `json:"version"`
+	Runs    []sarifRun `json:"runs"`
+}
+
+type sarifRun struct {
+	Tool       sarifTool     `json:"tool"`
+	ColumnKind string        `json:"columnKind"`
+	Results    []sarifResult `json:"results"`
+}
+
+type sarifTool struct {
+	Driver sarifDriver `json:"driver"`
+}
+
+type sarifDriver struct {
+	Name           string      `json:"name"`
+	InformationURI string      `json:"informationUri"`
+	Version        string      `json:"version,omitempty"`
+	Rules          []sarifRule `json:"rules"`
+}
+
+type sarifRule struct {
+	ID                   string             `json:"id"`
+	ShortDescription     sarifMessage       `json:"shortDescription"`
+	DefaultConfiguration sarifConfiguration `json:"defaultConfiguration"`
+}
+
+type sarifConfiguration struct {
+	Level string `json:"level"`
+}
+
+type sarifMessage struct {
+	Text string `json:"text"`
+}
+
+type sarifResult struct {
+	RuleID    string          `json:"ruleId"`
+	RuleIndex int             `json:"ruleIndex"`
+	Level     string          `json:"level"`
+	Message   sarifMessage    `json:"message"`
+	Locations []sarifLocation `json:"locations"`
+	Fixes     []sarifFix      `json:"fixes,omitempty"`
+}
+
+type sarifLocation struct {
+	PhysicalLocation sarifPhysicalLocation `json:"physicalLocation"`
+}
+
+type sarifPhysicalLocation struct {
+	ArtifactLocation sarifArtifactLocation `json:"artifactLocation"`
+	Region           *sarifRegion          `json:"region,omitempty"`
+}
+
+type sarifArtifactLocation struct {
+	URI string `json:"uri"`
+}
+
+type sarifRegion struct {
+	StartLine   int `json:"startLine"`
+	StartColumn int `json:"startColumn,omitempty"`
+	EndColumn   int `json:"endColumn,omitempty"`
+}
+
+type sarifFix struct {
+	Description     sarifMessage          `json:"description"`
+	ArtifactChanges []sarifArtifactChange `json:"artifactChanges"`
+}
+
+type sarifArtifactChange struct {
+	ArtifactLocation sarifArtifactLocation `json:"artifactLocation"`
+	Replacements     []sarifReplacement    `json:"replacements"`
+}
+
+type sarifReplacement struct {
+	DeletedRegion   sarifRegion  `json:"deletedRegion"`
+	InsertedContent sarifMessage `json:"insertedContent"`
+}
+
+// Rule IDs of SARIF results
+const (
+	RuleOutdatedValue     = "outdated-value"
+	RuleInvalidDefinition = "invalid-definition"
+	RuleCommandFailed     = "command-failed"
+)
+
+var sarifRules = []sarifRule{
+	{
+		ID:                   RuleOutdatedValue,
+		ShortDescription:     sarifMessage{Text: "The value differs from the result of the replacer"},
+		DefaultConfiguration: sarifConfiguration{Level: "warning"},
+	},
+	{
+		ID:                   RuleInvalidDefinition,
+		ShortDescription:     sarifMessage{Text: "The definition cannot be handled"},
+		DefaultConfiguration: sarifConfiguration{Level: "error"},
+	},
+	{
+		ID:                   RuleCommandFailed,
+		ShortDescription:     sarifMessage{Text: "The replacer command has been failed"},
+		DefaultConfiguration: sarifConfiguration{Level: "error"},
+	},
+}
+
+func sarifResultFor(ruleID string, message string, path string, region *sarifRegion) sarifResult {
+	index := 0
+	for i, r := range sarifRules {
+		if r.ID == ruleID {
+			index = i
+		}
+	}
+
+	return sarifResult{
+		RuleID:    ruleID,
+		RuleIndex: index,
+		Level:     sarifRules[index].DefaultConfiguration.Level,
+		Message:   sarifMessage{Text: message},
+		Locations: []sarifLocation{
+			{
+				PhysicalLocation: sarifPhysicalLocation{
+					ArtifactLocation: sarifArtifactLocation{URI: filepath.ToSlash(path)},
+					Region:           region,
+				},
+			},
+		},
+	}
+}
+
+// ErrorRule classifies the error into the rule ID
+func ErrorRule(err error) string {
+	var cmdErr *runner.CommandError
+	if xerrors.As(err, &cmdErr) {
+		return RuleCommandFailed
+	}
+
+	return RuleInvalidDefinition
+}
+
+// SARIF renders the outdated values and errors as a SARIF log for code scanning.
+// The outdated values have fixes with the replacement text.
+func SARIF(files []File, version string) ([]byte, error) {
+	results := []sarifResult{}
+	for _, f := range files {
+		if f.Err != nil {
+			var region *sarifRegion
+			if line, ok := ErrorLine(f.Err); ok {
+				region = &sarifRegion{StartLine: line}
+			}
+			results = append(results, sarifResultFor(ErrorRule(f.Err), ErrorMessage(f.Err), f.Path, region))
+			continue
+		}
+		for _, t := range f.Result.Targets {
+			if !t.IsChanged {
+				continue
+			}
+			region := sarifRegion{
+				StartLine:   t.LineNumber,
+				StartColumn: t.ValueRunes.Start + 1,
+				EndColumn:   t.ValueRunes.End + 1,
+			}
+			result := sarifResultFor(RuleOutdatedValue, fmt.Sprintf("%s %s is outdated (%s)", t.Name(), t.Extracted, t.Replacer), f.Path, &region)
+			result.Fixes = []sarifFix{
+				{
+					Description: sarifMessage{Text: fmt.Sprintf("Update %s %s -> %s", t.Name(), t.Extracted, t.Replacer)},
+					ArtifactChanges: []sarifArtifactChange{
+						{
+							ArtifactLocation: sarifArtifactLocation{URI: filepath.ToSlash(f.Path)},
+							Replacements: []sarifReplacement{
+								{DeletedRegion: region, InsertedContent: sarifMessage{Text: t.Replacer}},
+							},
+						},
+					},
+				},
+			}
+			results = append(results, result)
+		}
+	}
+
+	log := sarifLog{
+		Schema:  sarifSchema,
+		Version: sarifVersion,
+		Runs: []sarifRun{
+			{
+				Tool: sarifTool{
+					Driver: sarifDriver{
+						Name:           "selfup",
+						InformationURI: "https://github.com/kachick/selfup",
+						Version:        version,
+						Rules:          sarifRules,
+					},
+				},
+				ColumnKind: "unicodeCodePoints",
+				Results:    results,
+			},
+		},
+	}
+	// Keeps `->` in the messages readable
+	buf := new(bytes.Buffer)
+	encoder := json.NewEncoder(buf)
+	encoder.SetEscapeHTML(false)
+	encoder.SetIndent("", "  ")
+	err := encoder.Encode(log)
+	if err != nil {
+		return nil, xerrors.Errorf("Marshaling SARIF has been failed: %w", err)
+	}
+
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,64 @@
+package report
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/kachick/selfup/internal/runner"
+	"golang.org/x/xerrors"
+)
+
+func TestSARIF(t *testing.T) {
+	withErrors := []File{
+		files[0],
+		files[1],
+		{
+			Path: "broken.yml",
+			Err:  &runner.LineError{Line: 3, Err: xerrors.New("Invalid regex `[`")},
+		},
+		{
+			Path: "failed.yml",
+			Err: &runner.LineError{
+				Line: 5,
+				Err: xerrors.Errorf("Executing nix has been failed: %w", &runner.CommandError{
+					Argv:     []string{"nix", "eval"},
+					ExitCode: 1,
+					Duration: time.Second,
+					Stderr:   "error: broken\n",
+					Err:      xerrors.New("exit status 1"),
+				}),
+			},
+		},
+		{
+			Path: "missing.yml",
+			Err:  xerrors.New("open missing.yml: no such file or directory"),
+		},
+	}
+
+	got, err := SARIF(withErrors, "v0.0.1")
+	if err != nil {
+		t.Fatalf("unexpected error happened: %v", err)
+	}
+
+	golden := filepath.Join("testdata", "sarif.json")
+	want, err := os.ReadFile(golden)
+	if err != nil {
+		t.Fatalf("failed to read %s: %v", golden, err)
+	}
+	if diff := cmp.Diff(string(want), string(got)); diff != "" {
+		t.Errorf("wrong result: %s", diff)
+	}
+}
+
+func TestErrorRule(t *testing.T) {
+	cmdErr := &runner.LineError{Line: 1, Err: xerrors.Errorf("Executing: %w", &runner.CommandError{Err: xerrors.New("exit status 1")})}
+	if rule := ErrorRule(cmdErr); rule != RuleCommandFailed {
+		t.Errorf("wrong rule: %s", rule)
+	}
+	if rule := ErrorRule(&runner.LineError{Line: 1, Err: xerrors.New("Invalid regex")}); rule != RuleInvalidDefinition {
+		t.Errorf("wrong rule: %s", rule)
+	}
+}
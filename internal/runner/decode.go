@@ -0,0 +1,117 @@
+package runner
+
+import (
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+
+	"golang.org/x/xerrors"
+)
+
+// Keys of migrate.BetaSchema, they are not imported to avoid cyclic dependencies
+var betaFields = []string{"regex", "script"}
+
+func levenshtein(a string, b string) int {
+	ra := []rune(a)
+	rb := []rune(b)
+	prev := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		curr := make([]int, len(rb)+1)
+		curr[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			curr[j] = min(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+		}
+		prev = curr
+	}
+
+	return prev[len(rb)]
+}
+
+// Returns the closest known field for typos like "replacr" or "Nth"
+func suggestField(unknown string) (string, bool) {
+	best := ""
+	bestDistance := -1
+	for known := range definitionFields() {
+		distance := levenshtein(strings.ToLower(unknown), known)
+		if bestDistance < 0 || distance < bestDistance || (distance == bestDistance && known < best) {
+			best = known
+			bestDistance = distance
+		}
+	}
+	if bestDistance < 0 || bestDistance > max(1, len(best)/3) {
+		return "", false
+	}
+
+	return best, true
+}
+
+func unknownFieldMessage(unknown string) string {
+	if slices.Contains(betaFields, unknown) {
+		return fmt.Sprintf("Unknown field `%s` is a key of beta schema, run `selfup migrate` to convert it into v1 schema", unknown)
+	}
+	if suggestion, ok := suggestField(unknown); ok {
+		return fmt.Sprintf("Unknown field `%s`, did you mean `%s`?", unknown, suggestion)
+	}
+
+	return fmt.Sprintf("Unknown field `%s`", unknown)
+}
+
+// Returns keys which are not defined in Definition, keeping the order in the JSON
+func unknownFields(jsonStr string) []string {
+	members, err := objectMembers(jsonStr)
+	if err != nil {
+		return nil
+	}
+	fields := definitionFields()
+	unknowns := []string{}
+	for _, m := range members {
+		if _, ok := fields[m.key]; !ok {
+			unknowns = append(unknowns, m.key)
+		}
+	}
+
+	return unknowns
+}
+
+func decodeDefinition(jsonStr string, allowUnknownFields bool) (Definition, error) {
+	// Beta schema is always rejected, because it looks like an empty definition in v1 schema
+	for _, unknown := range unknownFields(jsonStr) {
+		if !allowUnknownFields || slices.Contains(betaFields, unknown) {
+			return Definition{}, xerrors.New(unknownFieldMessage(unknown))
+		}
+	}
+
+	def := Definition{}
+	dec := json.NewDecoder(strings.NewReader(jsonStr))
+	if !allowUnknownFields {
+		dec.DisallowUnknownFields()
+	}
+	err := dec.Decode(&def)
+	if err == nil {
+		if rest := jsonStr[dec.InputOffset():]; strings.TrimSpace(rest) != "" {
+			err = xerrors.Errorf("unexpected trailing data `%s`", rest)
+		}
+	}
+	if err != nil {
+		return Definition{}, xerrors.Errorf("Unmarsharing `%s` as JSON has been failed, check the given prefix: %w", jsonStr, err)
+	}
+	// Omitted extract means the implicit key, but an empty one is a mistake as in the schema
+	if def.Extract == "" && strings.Contains(jsonStr, `"extract"`) {
+		members, _ := objectMembers(jsonStr)
+		for _, m := range members {
+			if m.key == "extract" {
+				return Definition{}, xerrors.New("`extract` is empty, omit it to update the value of the key")
+			}
+		}
+	}
+
+	return def, nil
+}
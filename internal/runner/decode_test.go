@@ -0,0 +1,85 @@
+package runner
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestDecodeDefinition(t *testing.T) {
+	type testCase struct {
+		input              string
+		allowUnknownFields bool
+		want               Definition
+		wantErr            string
+	}
+	testCases := map[string]testCase{
+		"Valid": {
+			input: `{ "extract": "\\d+", "replacer": ["echo", "42"], "nth": 1 }`,
+			want:  Definition{Extract: "\\d+", Command: []string{"echo", "42"}, Nth: 1},
+		},
+		"Typo": {
+			input:   `{ "extract": "\\d+", "replacr": ["echo", "42"] }`,
+			wantErr: "Unknown field `replacr`, did you mean `replacer`?",
+		},
+		"Wrong case": {
+			input:   `{ "extract": "\\d+", "replacer": ["echo", "42"], "Nth": 1 }`,
+			wantErr: "Unknown field `Nth`, did you mean `nth`?",
+		},
+		"No suggestion": {
+			input:   `{ "extract": "\\d+", "replacer": ["echo", "42"], "comment": "foobar" }`,
+			wantErr: "Unknown field `comment`",
+		},
+		"Empty extract": {
+			input:   `{ "extract": "", "replacer": ["echo", "42"] }`,
+			wantErr: "`extract` is empty, omit it to update the value of the key",
+		},
+		"Omitted extract": {
+			input: `{ "replacer": ["echo", "42"] }`,
+			want:  Definition{Command: []string{"echo", "42"}},
+		},
+		"Beta schema": {
+			input:   `{ "regex": "\\d+", "script": "echo 42" }`,
+			wantErr: "Unknown field `regex` is a key of beta schema, run `selfup migrate` to convert it into v1 schema",
+		},
+		"Allow unknown fields": {
+			input:              `{ "extract": "\\d+", "replacer": ["echo", "42"], "comment": "foobar" }`,
+			allowUnknownFields: true,
+			want:               Definition{Extract: "\\d+", Command: []string{"echo", "42"}},
+		},
+		"Beta schema is not allowed even if allowing unknown fields": {
+			input:              `{ "regex": "\\d+", "script": "echo 42" }`,
+			allowUnknownFields: true,
+			wantErr:            "Unknown field `regex` is a key of beta schema, run `selfup migrate` to convert it into v1 schema",
+		},
+	}
+
+	for what, tc := range testCases {
+		t.Run(what, func(t *testing.T) {
+			def, err := decodeDefinition(tc.input, tc.allowUnknownFields)
+			if err != nil {
+				if tc.wantErr == "" {
+					t.Fatalf("unexpected error happened: %v", err)
+				}
+				if err.Error() != tc.wantErr {
+					t.Fatalf("wrong error: %v", err)
+				}
+				return
+			}
+			if tc.wantErr != "" {
+				t.Fatalf("expected error did not happen")
+			}
+
+			if diff := cmp.Diff(tc.want, def); diff != "" {
+				t.Errorf("wrong result: %s", diff)
+			}
+		})
+	}
+
+	t.Run("Trailing data", func(t *testing.T) {
+		_, err := decodeDefinition(`{ "extract": "\\d+", "replacer": ["echo", "42"] } -->`, false)
+		if err == nil {
+			t.Fatalf("expected error did not happen")
+		}
+	})
+}
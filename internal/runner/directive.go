@@ -0,0 +1,85 @@
+package runner
+
+import (
+	"bufio"
+	"io"
+	"regexp"
+)
+
+// Directives in comments to skip definitions
+const (
+	DirectiveIgnore         = "selfup-ignore"
+	DirectiveIgnoreNextLine = "selfup-ignore-next-line"
+	DirectiveDisable        = "selfup-disable"
+	DirectiveEnable         = "selfup-enable"
+	DirectiveIgnoreFile     = "selfup-ignore-file"
+)
+
+// Longer directives should be first, RE2 prefers the first alternative
+var directivePattern = regexp.MustCompile(`(?:#|//|;|/\*|<!--|--)\s*(selfup-(?:ignore-next-line|ignore-file|ignore|disable|enable))\b`)
+
+// Skipped is a definition skipped by a directive
+type Skipped struct {
+	LineNumber int
+	Directive  string
+}
+
+func directiveOf(line string) string {
+	match := directivePattern.FindStringSubmatch(line)
+	if match == nil {
+		return ""
+	}
+
+	return match[1]
+}
+
+// Returns the directive that skips each line, or empty if the line is not skipped
+func skippedBy(lines []string) []string {
+	directives := make([]string, len(lines))
+	for i, line := range lines {
+		directives[i] = directiveOf(line)
+	}
+
+	reasons := make([]string, len(lines))
+	for _, directive := range directives {
+		if directive == DirectiveIgnoreFile {
+			for i := range reasons {
+				reasons[i] = DirectiveIgnoreFile
+			}
+			return reasons
+		}
+	}
+
+	isDisabled := false
+	isNextIgnored := false
+	for i, directive := range directives {
+		switch directive {
+		case DirectiveDisable:
+			isDisabled = true
+		case DirectiveEnable:
+			isDisabled = false
+		}
+		switch {
+		case directive == DirectiveIgnore:
+			reasons[i] = DirectiveIgnore
+		case isNextIgnored:
+			reasons[i] = DirectiveIgnoreNextLine
+		case isDisabled:
+			reasons[i] = DirectiveDisable
+		}
+		isNextIgnored = directive == DirectiveIgnoreNextLine
+	}
+
+	return reasons
+}
+
+// Directives can be placed after the definitions, so all lines are read before handling
+func readLines(r io.Reader) ([]string, error) {
+	lines := []string{}
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		lines = append(lines, scanner.Text())
+	}
+
+	return lines, scanner.Err()
+}
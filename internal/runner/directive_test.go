@@ -0,0 +1,89 @@
+package runner
+
+import (
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestSkippedBy(t *testing.T) {
+	type testCase struct {
+		lines []string
+		want  []string
+	}
+	testCases := map[string]testCase{
+		"Ignore": {
+			lines: []string{"a # selfup-ignore", "b"},
+			want:  []string{DirectiveIgnore, ""},
+		},
+		"Ignore next line": {
+			lines: []string{"// selfup-ignore-next-line", "a", "b"},
+			want:  []string{"", DirectiveIgnoreNextLine, ""},
+		},
+		"Disable and enable": {
+			lines: []string{"a", "<!-- selfup-disable -->", "b", "c", "-- selfup-enable", "d"},
+			want:  []string{"", DirectiveDisable, DirectiveDisable, DirectiveDisable, "", ""},
+		},
+		"Disable without enable": {
+			lines: []string{"/* selfup-disable */", "a"},
+			want:  []string{DirectiveDisable, DirectiveDisable},
+		},
+		"Ignore file at the end": {
+			lines: []string{"a", "b", "; selfup-ignore-file"},
+			want:  []string{DirectiveIgnoreFile, DirectiveIgnoreFile, DirectiveIgnoreFile},
+		},
+		"Not in comments": {
+			lines: []string{"selfup-ignore", "a", "# selfup-ignored"},
+			want:  []string{"", "", ""},
+		},
+	}
+
+	for what, tc := range testCases {
+		t.Run(what, func(t *testing.T) {
+			if diff := cmp.Diff(tc.want, skippedBy(tc.lines)); diff != "" {
+				t.Errorf("wrong result: %s", diff)
+			}
+		})
+	}
+}
+
+func TestDryRunDirectives(t *testing.T) {
+	input := `ignored: '0.39.0' # selfup { "extract": "\\d[^']+", "replacer": ["do_not_run_this"] } # selfup-ignore
+# selfup-ignore-next-line
+example: '0.39.0' # selfup {{ """" }
+# selfup-disable
+disabled: '0.39.0' # selfup { "extract": "\\d[^']+", "replacer": ["do_not_run_this"] }
+# selfup-enable
+will_be_replaced: '0.39.0' # selfup { "extract": "\\d[^']+", "replacer": ["echo", "0.76.9"] }
+`
+	result, err := DryRunWith(strings.NewReader(input), Options{Prefix: regexp.MustCompile(defaultPrefix), Executor: echoExecutor})
+	if err != nil {
+		t.Fatalf("unexpected error happened: %v", err)
+	}
+
+	want := []Skipped{
+		{LineNumber: 1, Directive: DirectiveIgnore},
+		{LineNumber: 3, Directive: DirectiveIgnoreNextLine},
+		{LineNumber: 5, Directive: DirectiveDisable},
+	}
+	if diff := cmp.Diff(want, result.Skipped); diff != "" {
+		t.Errorf("wrong result: %s", diff)
+	}
+	if result.Total != 1 || result.ChangedCount != 1 {
+		t.Errorf("expected skipped definitions are not counted: %d/%d", result.ChangedCount, result.Total)
+	}
+	if lines := strings.Split(input, "\n"); !cmp.Equal(lines[:6], result.NewLines[:6]) {
+		t.Errorf("expected skipped lines are kept: %v", result.NewLines)
+	}
+
+	ignoredFile := input + "# selfup-ignore-file\n"
+	result, err = DryRunWith(strings.NewReader(ignoredFile), Options{Prefix: regexp.MustCompile(defaultPrefix), Executor: echoExecutor})
+	if err != nil {
+		t.Fatalf("unexpected error happened: %v", err)
+	}
+	if result.Total != 0 || len(result.Skipped) != 4 {
+		t.Errorf("expected all definitions are skipped: %v", result)
+	}
+}
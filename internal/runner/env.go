@@ -0,0 +1,94 @@
+package runner
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"golang.org/x/xerrors"
+)
+
+// Env modifies the environment variables for the replacer
+type Env struct {
+	// Overrides the variables
+	Set map[string]string `json:"set,omitempty"`
+	// Removes the variables
+	Unset []string `json:"unset,omitempty"`
+	// Passes only these variables and DefaultAllowedEnv from the environment
+	Allow []string `json:"allow,omitempty"`
+}
+
+// Passed even in the clean environment, because many commands do not work without them
+var DefaultAllowedEnv = []string{"PATH", "HOME", "USER", "LANG", "LC_ALL", "TZ", "TMPDIR", "TERM"}
+
+func buildEnv(environ []string, env *Env, clean bool) []string {
+	if env == nil {
+		env = &Env{}
+	}
+	if !clean && len(env.Allow) == 0 && len(env.Unset) == 0 && len(env.Set) == 0 {
+		// Inherits the environment of selfup
+		return nil
+	}
+
+	built := []string{}
+	for _, kv := range environ {
+		name, _, _ := strings.Cut(kv, "=")
+		if (clean || len(env.Allow) > 0) && !slices.Contains(DefaultAllowedEnv, name) && !slices.Contains(env.Allow, name) {
+			continue
+		}
+		if slices.Contains(env.Unset, name) {
+			continue
+		}
+		if _, ok := env.Set[name]; ok {
+			continue
+		}
+		built = append(built, kv)
+	}
+	names := []string{}
+	for name := range env.Set {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	for _, name := range names {
+		built = append(built, name+"="+env.Set[name])
+	}
+
+	return built
+}
+
+// Resolves "cwd" of the definition.
+// Relative paths are based on the annotated file, and paths starting with "/" are based on the repository root.
+func resolveDir(cwd string, dir string, root string) string {
+	if cwd == "" {
+		return ""
+	}
+	if strings.HasPrefix(cwd, "/") {
+		return filepath.Join(root, filepath.FromSlash(strings.TrimLeft(cwd, "/")))
+	}
+
+	return filepath.Join(dir, filepath.FromSlash(cwd))
+}
+
+func (opts Options) command(def Definition) (Command, error) {
+	if len(def.Command) < 1 {
+		return Command{}, xerrors.New("No commands are given")
+	}
+
+	dir := resolveDir(def.Cwd, opts.Dir, opts.Root)
+	if dir != "" {
+		info, err := os.Stat(dir)
+		if err != nil {
+			return Command{}, xerrors.Errorf("Invalid cwd `%s`: %w", def.Cwd, err)
+		}
+		if !info.IsDir() {
+			return Command{}, xerrors.Errorf("Invalid cwd `%s`: not a directory", def.Cwd)
+		}
+	}
+
+	return Command{
+		Argv: def.Command,
+		Dir:  dir,
+		Env:  buildEnv(os.Environ(), def.Env, opts.CleanEnv),
+	}, nil
+}
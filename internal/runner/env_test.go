@@ -0,0 +1,91 @@
+package runner
+
+import (
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestBuildEnv(t *testing.T) {
+	environ := []string{"PATH=/bin", "HOME=/home/user", "SECRET=42", "EDITOR=vim"}
+
+	type testCase struct {
+		env   *Env
+		clean bool
+		want  []string
+	}
+	testCases := map[string]testCase{
+		"Inherits without modifications": {
+			env:  nil,
+			want: nil,
+		},
+		"Set and unset": {
+			env:  &Env{Set: map[string]string{"EDITOR": "nano", "LANG": "C"}, Unset: []string{"SECRET"}},
+			want: []string{"PATH=/bin", "HOME=/home/user", "EDITOR=nano", "LANG=C"},
+		},
+		"Allow": {
+			env:  &Env{Allow: []string{"EDITOR"}},
+			want: []string{"PATH=/bin", "HOME=/home/user", "EDITOR=vim"},
+		},
+		"Clean": {
+			env:   nil,
+			clean: true,
+			want:  []string{"PATH=/bin", "HOME=/home/user"},
+		},
+		"Clean with set": {
+			env:   &Env{Set: map[string]string{"TOKEN": "dummy"}},
+			clean: true,
+			want:  []string{"PATH=/bin", "HOME=/home/user", "TOKEN=dummy"},
+		},
+	}
+
+	for what, tc := range testCases {
+		t.Run(what, func(t *testing.T) {
+			if diff := cmp.Diff(tc.want, buildEnv(environ, tc.env, tc.clean)); diff != "" {
+				t.Errorf("wrong result: %s", diff)
+			}
+		})
+	}
+}
+
+func TestDryRunWithCwd(t *testing.T) {
+	root := t.TempDir()
+	dir := filepath.Join(root, "sub")
+	err := os.Mkdir(dir, 0755)
+	if err != nil {
+		t.Fatalf("failed to create a directory: %v", err)
+	}
+	input := `Header
+relative: '0.39.0' # selfup { "extract": "\\d[^']+", "replacer": ["supertool"], "cwd": "." }
+rooted: '0.39.0' # selfup { "extract": "\\d[^']+", "replacer": ["supertool"], "cwd": "/" }
+inherited: '0.39.0' # selfup { "extract": "\\d[^']+", "replacer": ["supertool"] }
+`
+	recorder := &RecordingExecutor{Next: FakeExecutor{Outputs: map[string]string{"supertool": "0.76.9"}}}
+
+	_, err = DryRunWith(strings.NewReader(input), Options{Prefix: regexp.MustCompile(defaultPrefix), Executor: recorder, Dir: dir, Root: root})
+	if err != nil {
+		t.Fatalf("unexpected error happened: %v", err)
+	}
+
+	want := []Command{
+		{Argv: []string{"supertool"}, Dir: dir},
+		{Argv: []string{"supertool"}, Dir: root},
+		{Argv: []string{"supertool"}},
+	}
+	if diff := cmp.Diff(want, recorder.Commands()); diff != "" {
+		t.Errorf("wrong commands: %s", diff)
+	}
+
+	t.Run("Not found", func(t *testing.T) {
+		input := `missing: '0.39.0' # selfup { "extract": "\\d[^']+", "replacer": ["supertool"], "cwd": "missing" }
+`
+		_, err := DryRunWith(strings.NewReader(input), Options{Prefix: regexp.MustCompile(defaultPrefix), Executor: recorder, Dir: dir, Root: root})
+		if err == nil {
+			t.Fatalf("expected error did not happen")
+		}
+	})
+}
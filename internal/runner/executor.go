@@ -0,0 +1,139 @@
+package runner
+
+import (
+	"fmt"
+	"os/exec"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/xerrors"
+)
+
+// Command is what the Executor runs for a replacer
+type Command struct {
+	Argv []string
+	// Inherits the working directory of selfup if empty
+	Dir string
+	// Inherits the environment of selfup if nil, the format is same as os.Environ
+	Env []string
+}
+
+func (c Command) String() string {
+	return strings.Join(c.Argv, " ")
+}
+
+// Executor runs the replacer command and returns the STDOUT
+type Executor interface {
+	Execute(cmd Command) ([]byte, error)
+}
+
+// ExecutorFunc is an adapter to use ordinary functions as Executor
+type ExecutorFunc func(cmd Command) ([]byte, error)
+
+func (f ExecutorFunc) Execute(cmd Command) ([]byte, error) {
+	return f(cmd)
+}
+
+// Keeps the end of STDERR in errors, because the last lines often explain the failure
+const StderrTailLimit = 2048
+
+// CommandError is returned when the replacer command has been failed
+type CommandError struct {
+	Argv []string
+	// -1 if the command did not exit, e.g. not found
+	ExitCode int
+	Duration time.Duration
+	// Truncated to the last StderrTailLimit bytes
+	Stderr string
+	Err    error
+}
+
+func (e *CommandError) Error() string {
+	return fmt.Sprintf("`%s` exited with %d after %s: %v", strings.Join(e.Argv, " "), e.ExitCode, e.Duration.Round(time.Millisecond), e.Err)
+}
+
+func (e *CommandError) Unwrap() error {
+	return e.Err
+}
+
+func stderrTail(stderr []byte) string {
+	if len(stderr) <= StderrTailLimit {
+		return string(stderr)
+	}
+
+	return "..." + string(stderr[len(stderr)-StderrTailLimit:])
+}
+
+// ExecExecutor runs commands with os/exec
+type ExecExecutor struct{}
+
+func (ExecExecutor) Execute(cmd Command) ([]byte, error) {
+	started := time.Now()
+	c := exec.Command(cmd.Argv[0], cmd.Argv[1:]...)
+	c.Dir = cmd.Dir
+	c.Env = cmd.Env
+	out, err := c.Output()
+	if err != nil {
+		cmdErr := &CommandError{
+			Argv:     slices.Clone(cmd.Argv),
+			ExitCode: -1,
+			Duration: time.Since(started),
+			Err:      err,
+		}
+		var exitErr *exec.ExitError
+		if xerrors.As(err, &exitErr) {
+			cmdErr.ExitCode = exitErr.ExitCode()
+			cmdErr.Stderr = stderrTail(exitErr.Stderr)
+		}
+		return nil, cmdErr
+	}
+
+	return out, nil
+}
+
+// FakeExecutor returns canned outputs without executing any command
+type FakeExecutor struct {
+	// Keyed by the argv joined with spaces
+	Outputs map[string]string
+}
+
+func (f FakeExecutor) Execute(cmd Command) ([]byte, error) {
+	out, ok := f.Outputs[cmd.String()]
+	if !ok {
+		return nil, xerrors.Errorf("No output is prepared for `%s`", cmd)
+	}
+
+	return []byte(out), nil
+}
+
+// RecordingExecutor records the commands and delegates the execution to Next
+type RecordingExecutor struct {
+	// Defaults to ExecExecutor
+	Next Executor
+
+	mu       sync.Mutex
+	commands []Command
+}
+
+func (r *RecordingExecutor) Execute(cmd Command) ([]byte, error) {
+	r.mu.Lock()
+	r.commands = append(r.commands, Command{Argv: slices.Clone(cmd.Argv), Dir: cmd.Dir, Env: slices.Clone(cmd.Env)})
+	r.mu.Unlock()
+
+	next := r.Next
+	if next == nil {
+		next = ExecExecutor{}
+	}
+
+	return next.Execute(cmd)
+}
+
+// Commands returns the recorded commands in the executed order
+func (r *RecordingExecutor) Commands() []Command {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return slices.Clone(r.commands)
+}
@@ -0,0 +1,108 @@
+package runner
+
+import (
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"golang.org/x/xerrors"
+)
+
+func TestFakeExecutor(t *testing.T) {
+	executor := FakeExecutor{Outputs: map[string]string{"supertool --version": "supertool 0.76.9\n"}}
+
+	out, err := executor.Execute(Command{Argv: []string{"supertool", "--version"}})
+	if err != nil {
+		t.Fatalf("unexpected error happened: %v", err)
+	}
+	if string(out) != "supertool 0.76.9\n" {
+		t.Errorf("wrong output: %s", out)
+	}
+
+	_, err = executor.Execute(Command{Argv: []string{"supertool", "--help"}})
+	if err == nil {
+		t.Fatalf("expected error did not happen")
+	}
+}
+
+func TestRecordingExecutor(t *testing.T) {
+	input := `Header
+will_be_replaced: '0.39.0' # selfup { "extract": "\\d[^']+", "replacer": ["supertool", "--version"], "nth": 2 }
+skipped: '0.39.0' # selfup { "extract": "\\d[^']+", "replacer": ["do_not_run_this"] }
+not_be_replaced: '0.39.0' # selfup { "extract": "\\d[^']+", "replacer": ["othertool", "version"] }
+`
+	recorder := &RecordingExecutor{
+		Next: FakeExecutor{Outputs: map[string]string{
+			"supertool --version": "supertool 0.76.9\n",
+			"othertool version":   "0.39.0",
+		}},
+	}
+
+	result, err := DryRunWith(strings.NewReader(input), Options{Prefix: regexp.MustCompile(defaultPrefix), SkipBy: "skipped", Executor: recorder})
+	if err != nil {
+		t.Fatalf("unexpected error happened: %v", err)
+	}
+	if result.ChangedCount != 1 {
+		t.Errorf("expected 1 change, got %d", result.ChangedCount)
+	}
+
+	want := []Command{
+		{Argv: []string{"supertool", "--version"}},
+		{Argv: []string{"othertool", "version"}},
+	}
+	if diff := cmp.Diff(want, recorder.Commands()); diff != "" {
+		t.Errorf("wrong commands: %s", diff)
+	}
+}
+
+func TestExecExecutor(t *testing.T) {
+	t.Run("Succeeded", func(t *testing.T) {
+		out, err := ExecExecutor{}.Execute(Command{Argv: []string{"sh", "-c", "echo 0.76.9"}})
+		if err != nil {
+			t.Fatalf("unexpected error happened: %v", err)
+		}
+		if string(out) != "0.76.9\n" {
+			t.Errorf("wrong output: %s", out)
+		}
+	})
+
+	t.Run("Failed", func(t *testing.T) {
+		_, err := ExecExecutor{}.Execute(Command{Argv: []string{"sh", "-c", "echo 'supertool: not found' >&2; exit 3"}})
+		var cmdErr *CommandError
+		if !xerrors.As(err, &cmdErr) {
+			t.Fatalf("expected CommandError, got %v", err)
+		}
+		if cmdErr.ExitCode != 3 {
+			t.Errorf("wrong exit code: %d", cmdErr.ExitCode)
+		}
+		if cmdErr.Stderr != "supertool: not found\n" {
+			t.Errorf("wrong stderr: %s", cmdErr.Stderr)
+		}
+		if diff := cmp.Diff([]string{"sh", "-c", "echo 'supertool: not found' >&2; exit 3"}, cmdErr.Argv); diff != "" {
+			t.Errorf("wrong argv: %s", diff)
+		}
+	})
+
+	t.Run("Not found", func(t *testing.T) {
+		_, err := ExecExecutor{}.Execute(Command{Argv: []string{"this_command_does_not_exist"}})
+		var cmdErr *CommandError
+		if !xerrors.As(err, &cmdErr) {
+			t.Fatalf("expected CommandError, got %v", err)
+		}
+		if cmdErr.ExitCode != -1 {
+			t.Errorf("wrong exit code: %d", cmdErr.ExitCode)
+		}
+	})
+
+	t.Run("Truncated stderr", func(t *testing.T) {
+		_, err := ExecExecutor{}.Execute(Command{Argv: []string{"sh", "-c", "head -c 5000 /dev/zero | tr '\\0' x >&2; echo END >&2; exit 1"}})
+		var cmdErr *CommandError
+		if !xerrors.As(err, &cmdErr) {
+			t.Fatalf("expected CommandError, got %v", err)
+		}
+		if len(cmdErr.Stderr) != StderrTailLimit+len("...") || !strings.HasSuffix(cmdErr.Stderr, "xEND\n") {
+			t.Errorf("wrong stderr tail: %d bytes", len(cmdErr.Stderr))
+		}
+	})
+}
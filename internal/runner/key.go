@@ -0,0 +1,61 @@
+package runner
+
+import (
+	"regexp"
+
+	"github.com/kachick/selfup/internal/structured"
+	"golang.org/x/xerrors"
+)
+
+// Returns the scalar of the key path, or the scalar in the line of the definition for the implicit key
+func locateScalar(scalars []structured.Scalar, key string, index int) (structured.Scalar, error) {
+	for _, s := range scalars {
+		if (key != "" && s.Path == key) || (key == "" && s.Line == index) {
+			return s, nil
+		}
+	}
+	if key != "" {
+		return structured.Scalar{}, xerrors.Errorf("Key `%s` is not found or not a scalar", key)
+	}
+
+	return structured.Scalar{}, xerrors.New("No values are found for the implicit key in this line, specify `extract` or `key`")
+}
+
+// Returns the target and the updated line of the scalar with the replacer, keeping the quote style.
+// The extractor narrows down the updated part in the scalar if `extract` is given.
+// Extracted and Replacer in the target are written as in the file, including escapes.
+func editScalar(lines []string, scalars []structured.Scalar, index int, def Definition, extractor *regexp.Regexp, replacer string) (Target, string, error) {
+	scalar, err := locateScalar(scalars, def.KeyPath, index)
+	if err != nil {
+		return Target{}, "", err
+	}
+	encoded, err := scalar.Encode(replacer)
+	if err != nil {
+		return Target{}, "", err
+	}
+	line := lines[scalar.Line]
+	start, end := scalar.Start, scalar.End
+	if def.Extract != "" {
+		value := line[scalar.Start:scalar.End]
+		location := extractor.FindStringIndex(value)
+		if location == nil {
+			return Target{}, "", xerrors.Errorf("`extract` does not match the value of `%s`: %s", scalar.Path, value)
+		}
+		if extractor.FindString(value[:location[0]]+encoded+value[location[1]:]) != encoded {
+			return Target{}, "", xerrors.Errorf("The result of updater command has malformed format: %s", replacer)
+		}
+		start, end = scalar.Start+location[0], scalar.Start+location[1]
+	}
+	extracted := line[start:end]
+
+	return Target{
+		LineNumber: scalar.Line + 1,
+		Extracted:  extracted,
+		Replacer:   encoded,
+		IsChanged:  extracted != encoded,
+		ID:         def.ID,
+		Command:    def.Command,
+		ValueBytes: Span{Start: start, End: end},
+		ValueRunes: runeSpan(line, start, end),
+	}, line[:start] + encoded + line[end:], nil
+}
@@ -0,0 +1,131 @@
+package runner
+
+import (
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/kachick/selfup/internal/structured"
+	"golang.org/x/xerrors"
+)
+
+func TestDryRunKey(t *testing.T) {
+	type testCase struct {
+		input       string
+		format      structured.Format
+		wantLines   []string
+		wantTargets []Target
+	}
+
+	testCases := map[string]testCase{
+		"Implicit key in YAML": {
+			input: `dprint-version: '0.39.0' # selfup { "replacer": ["echo", "it's 0.76.9"] }
+`,
+			format:    structured.YAML{},
+			wantLines: []string{`dprint-version: 'it''s 0.76.9' # selfup { "replacer": ["echo", "it's 0.76.9"] }`},
+			wantTargets: []Target{
+				{
+					LineNumber: 1, AnnotationLineNumber: 1, Extracted: "0.39.0", Replacer: "it''s 0.76.9", IsChanged: true, Command: []string{"echo", "it's 0.76.9"},
+					ValueBytes: Span{Start: 17, End: 23}, ValueRunes: Span{Start: 17, End: 23},
+					AnnotationBytes: Span{Start: 34, End: 73}, AnnotationRunes: Span{Start: 34, End: 73},
+				},
+			},
+		},
+		"Key in another line of TOML": {
+			input: `# selfup { "key": "tools.dprint", "replacer": ["echo", "0.76.9"] }
+[tools]
+dprint = "0.39.0" # comment
+`,
+			format: structured.TOML{},
+			wantLines: []string{
+				`# selfup { "key": "tools.dprint", "replacer": ["echo", "0.76.9"] }`,
+				`[tools]`,
+				`dprint = "0.76.9" # comment`,
+			},
+			wantTargets: []Target{
+				{
+					LineNumber: 3, AnnotationLineNumber: 1, Extracted: "0.39.0", Replacer: "0.76.9", IsChanged: true, Command: []string{"echo", "0.76.9"},
+					ValueBytes: Span{Start: 10, End: 16}, ValueRunes: Span{Start: 10, End: 16},
+					AnnotationBytes: Span{Start: 9, End: 66}, AnnotationRunes: Span{Start: 9, End: 66},
+				},
+			},
+		},
+		"Extract in the value of JSON": {
+			input: `{
+  // selfup { "key": "plugins[0]", "extract": "\\d+\\.\\d+\\.\\d+", "replacer": ["echo", "0.19.1"] }
+  "plugins": ["https://plugins.dprint.dev/json-0.19.0.wasm"]
+}
+`,
+			format: structured.JSON{},
+			wantLines: []string{
+				`{`,
+				`  // selfup { "key": "plugins[0]", "extract": "\\d+\\.\\d+\\.\\d+", "replacer": ["echo", "0.19.1"] }`,
+				`  "plugins": ["https://plugins.dprint.dev/json-0.19.1.wasm"]`,
+				`}`,
+			},
+			wantTargets: []Target{
+				{
+					LineNumber: 3, AnnotationLineNumber: 2, Extracted: "0.19.0", Replacer: "0.19.1", IsChanged: true, Command: []string{"echo", "0.19.1"},
+					ValueBytes: Span{Start: 47, End: 53}, ValueRunes: Span{Start: 47, End: 53},
+					AnnotationBytes: Span{Start: 12, End: 100}, AnnotationRunes: Span{Start: 12, End: 100},
+				},
+			},
+		},
+	}
+
+	for what, tc := range testCases {
+		t.Run(what, func(t *testing.T) {
+			result, err := DryRunWith(strings.NewReader(tc.input), Options{
+				Prefix:   regexp.MustCompile(defaultPrefix),
+				Format:   tc.format,
+				Executor: echoExecutor,
+			})
+			if err != nil {
+				t.Fatalf("unexpected error happened: %v", err)
+			}
+			if diff := cmp.Diff(tc.wantLines, result.NewLines); diff != "" {
+				t.Errorf("wrong result: %s", diff)
+			}
+			if diff := cmp.Diff(tc.wantTargets, result.Targets); diff != "" {
+				t.Errorf("wrong result: %s", diff)
+			}
+		})
+	}
+
+	// Replacers have different lengths from the current value to detect broken offsets
+	conflicts := map[string]string{
+		"Key and implicit key": `# selfup { "key": "dprint", "replacer": ["echo", "10.400.2000"] }
+dprint: "0.39.0" # selfup { "replacer": ["echo", "0.41.0"] }
+`,
+		"Regex and key": `version: 0.39.0 # selfup { "extract": "\\d[^ ]+", "replacer": ["echo", "10.400.2000"] }
+# selfup { "key": "version", "replacer": ["echo", "0.41.0"] }
+`,
+	}
+	for what, input := range conflicts {
+		t.Run(what, func(t *testing.T) {
+			_, err := DryRunWith(strings.NewReader(input), Options{
+				Prefix:   regexp.MustCompile(defaultPrefix),
+				Format:   structured.YAML{},
+				Executor: echoExecutor,
+			})
+			if err == nil {
+				t.Fatalf("expected error did not happen")
+			}
+			var lineErr *LineError
+			if !xerrors.As(err, &lineErr) || lineErr.Line != 2 || !strings.Contains(err.Error(), "already updated by line 1") {
+				t.Errorf("wrong error: %v", err)
+			}
+		})
+	}
+
+	t.Run("Without format", func(t *testing.T) {
+		_, err := DryRunWith(strings.NewReader(`v: '0.39.0' # selfup { "replacer": ["echo", "0.76.9"] }`), Options{
+			Prefix:   regexp.MustCompile(defaultPrefix),
+			Executor: echoExecutor,
+		})
+		if err == nil {
+			t.Fatalf("expected error did not happen")
+		}
+	})
+}
@@ -1,35 +1,168 @@
 package runner
 
 import (
-	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
+	"fmt"
 	"io"
-	"os/exec"
+	"path/filepath"
 	"regexp"
+	"slices"
 	"strings"
+	"unicode/utf8"
 
+	"github.com/kachick/selfup/internal/structured"
+	"github.com/kachick/selfup/internal/syntax"
 	"golang.org/x/xerrors"
 )
 
+const DefaultPrefix string = "\\s*[#;/]* selfup "
+
 type Definition struct {
-	Extract   string   `json:"extract"`
+	Extract string `json:"extract"`
+	// Key path of the value in YAML, TOML and JSON, like "jobs.lint.steps[1].with.version"
+	KeyPath   string   `json:"key"`
 	Command   []string `json:"replacer"`
 	Nth       int      `json:"nth"`
 	Delimiter string   `json:"delimiter"`
+	ID        string   `json:"id"`
+	Cwd       string   `json:"cwd"`
+	Env       *Env     `json:"env"`
+}
+
+// Key identifies the definition in lock files.
+// It prefers the ID and falls back to a hash of how the replacer is resolved.
+func (d Definition) Key() string {
+	if d.ID != "" {
+		return d.ID
+	}
+
+	resolving, _ := json.Marshal(struct {
+		Command   []string `json:"replacer"`
+		Nth       int      `json:"nth,omitempty"`
+		Delimiter string   `json:"delimiter,omitempty"`
+		Cwd       string   `json:"cwd,omitempty"`
+		Env       *Env     `json:"env,omitempty"`
+	}{d.Command, d.Nth, d.Delimiter, d.Cwd, d.Env})
+	sum := sha256.Sum256(resolving)
+
+	return "sha256:" + hex.EncodeToString(sum[:])
+}
+
+// Resolver returns the string that should replace the extracted string.
+// The cmd is built from the definition with the working directory and environment for it.
+type Resolver interface {
+	Resolve(def Definition, cmd Command) (string, error)
+}
+
+// CommandResolver executes the replacer command and picks the field
+type CommandResolver struct {
+	// Defaults to ExecExecutor
+	Executor Executor
+}
+
+func (r CommandResolver) Resolve(def Definition, cmd Command) (string, error) {
+	if len(cmd.Argv) < 1 {
+		return "", xerrors.New("No commands are given")
+	}
+	executor := r.Executor
+	if executor == nil {
+		executor = ExecExecutor{}
+	}
+	out, err := executor.Execute(cmd)
+	if err != nil {
+		return "", xerrors.Errorf("Executing %s has been failed: %w", cmd.Argv[0], err)
+	}
+	cmdResult := strings.TrimSuffix(string(out), "\n")
+	if def.Nth < 1 {
+		return cmdResult, nil
+	}
+
+	var fields []string
+	if def.Delimiter == "" {
+		fields = strings.Fields(cmdResult)
+	} else {
+		fields = strings.Split(cmdResult, def.Delimiter)
+	}
+	if def.Nth > len(fields) {
+		return "", xerrors.Errorf("Accessing invalid fields: STDOUT:%s Delimiter:%s Nth:%d", cmdResult, def.Delimiter, def.Nth)
+	}
+
+	return fields[def.Nth-1], nil
+}
+
+// LineError is an error for the line of the definition
+type LineError struct {
+	Line int
+	Err  error
+}
+
+func (e *LineError) Error() string {
+	return fmt.Sprint(e)
+}
+
+func (e *LineError) Format(s fmt.State, v rune) {
+	xerrors.FormatError(e, s, v)
+}
+
+func (e *LineError) FormatError(p xerrors.Printer) error {
+	p.Printf("%d", e.Line)
+	return e.Err
+}
+
+func (e *LineError) Unwrap() error {
+	return e.Err
+}
+
+// Span is a half-open range of 0-based offsets in the line
+type Span struct {
+	Start int
+	End   int
+}
+
+func runeSpan(line string, start int, end int) Span {
+	return Span{
+		Start: utf8.RuneCountInString(line[:start]),
+		End:   utf8.RuneCountInString(line[:end]),
+	}
 }
 
 type Target struct {
-	LineNumber int
-	Extracted  string
-	Replacer   string
-	IsChanged  bool
+	// Line of the value, it differs from AnnotationLineNumber when `key` points another line
+	LineNumber           int
+	AnnotationLineNumber int
+	Extracted            string
+	Replacer             string
+	IsChanged            bool
+	ID                   string
+	Command              []string
+	// Positions in the original lines, in bytes and in characters(runes)
+	ValueBytes      Span
+	ValueRunes      Span
+	AnnotationBytes Span
+	AnnotationRunes Span
+}
+
+// Name is a human readable label, the ID or the executable
+func (t Target) Name() string {
+	if t.ID != "" {
+		return t.ID
+	}
+	if len(t.Command) > 0 {
+		return filepath.Base(t.Command[0])
+	}
+
+	return ""
 }
 
 type Result struct {
 	NewLines     []string
 	Targets      []Target
 	ChangedCount int
-	Total        int
+	// Skipped definitions are not counted
+	Total   int
+	Skipped []Skipped
 }
 
 // Like a ruby's String#partition
@@ -47,92 +180,207 @@ func partition(s string, sep *regexp.Regexp) (before string, separator string, a
 	return before, separator, after, true
 }
 
+// Finds the definition JSON after the prefix and before the last match of the suffix.
+// The suffix is optional, and the text after the JSON is kept in the line.
+func split(line string, prefix *regexp.Regexp, suffix *regexp.Regexp) (head string, separator string, jsonStr string, found bool) {
+	head, separator, after, found := partition(line, prefix)
+	if !found || len(after) == 0 || after[0] != '{' {
+		return "", "", "", false
+	}
+	jsonStr = after
+	if suffix != nil {
+		if locations := suffix.FindAllStringIndex(after, -1); locations != nil {
+			jsonStr = after[:locations[len(locations)-1][0]]
+		}
+	}
+
+	return head, separator, jsonStr, true
+}
+
+// Returns the comments of the lines, or nil to accept definitions anywhere
+func commentsOf(language *syntax.Language, lines []string) [][]syntax.Span {
+	if language == nil {
+		return nil
+	}
+
+	return language.Comments(lines)
+}
+
+// Like split, but the definition should start in a comment if the comments are given
+func splitInComment(lines []string, i int, prefix *regexp.Regexp, suffix *regexp.Regexp, comments [][]syntax.Span) (head string, separator string, jsonStr string, found bool) {
+	head, separator, jsonStr, found = split(lines[i], prefix, suffix)
+	if found && comments != nil && !syntax.Contains(comments[i], len(head)+len(separator)) {
+		return "", "", "", false
+	}
+
+	return head, separator, jsonStr, found
+}
+
+type Options struct {
+	Prefix *regexp.Regexp
+	// Optional pattern after the JSON like ` -->` in HTML comments
+	Suffix *regexp.Regexp
+	// Handles only the definitions in comments of this language, all lines are handled if nil
+	Language *syntax.Language
+	// Locates the values with `key` or without `extract` in definitions, they are errors if nil
+	Format structured.Format
+	SkipBy string
+	// Defaults to CommandResolver with the Executor
+	Resolver Resolver
+	// Defaults to ExecExecutor
+	Executor Executor
+	// Ignores unknown fields in definitions instead of raising errors
+	AllowUnknownFields bool
+	// Directory of the annotated file, relative "cwd" in definitions are based on this
+	Dir string
+	// Repository root, "cwd" starting with "/" in definitions are based on this
+	Root string
+	// Passes only DefaultAllowedEnv and allowed variables in definitions to replacers
+	CleanEnv bool
+	// Handles only the definitions that this returns true, others are kept without executing replacers
+	Filter func(lineNumber int, def Definition) bool
+}
+
 func DryRun(r io.Reader, prefix *regexp.Regexp, skipBy string) (Result, error) {
-	newLines := []string{}
+	return DryRunWith(r, Options{Prefix: prefix, SkipBy: skipBy})
+}
+
+func DryRunWith(r io.Reader, opts Options) (Result, error) {
+	prefix := opts.Prefix
+	skipBy := opts.SkipBy
+	resolver := opts.Resolver
+	if resolver == nil {
+		resolver = CommandResolver{Executor: opts.Executor}
+	}
+
 	targets := []Target{}
+	var skipped []Skipped
 
-	scanner := bufio.NewScanner(r)
-	lineNumber := 0
+	lines, err := readLines(r)
+	if err != nil {
+		return Result{}, err
+	}
+	// Values can be updated in other lines than the definitions with `key`
+	newLines := slices.Clone(lines)
+	// Line index of the updated values and the line number of the definition, one line cannot be updated twice
+	updatedBy := map[int]int{}
+	var scalars []structured.Scalar
+	reasons := skippedBy(lines)
+	comments := commentsOf(opts.Language, lines)
 	totalCount := 0
 	changedCount := 0
 
-	for scanner.Scan() {
-		lineNumber += 1
-		line := scanner.Text()
+	for i, line := range lines {
+		lineNumber := i + 1
 		if skipBy != "" && strings.Contains(line, skipBy) {
-			newLines = append(newLines, line)
 			continue
 		}
-		headWithVersion, separator, jsonStr, found := partition(line, prefix)
-		if !found || len(jsonStr) == 0 || jsonStr[0] != '{' {
-			newLines = append(newLines, line)
+		headWithVersion, separator, jsonStr, found := splitInComment(lines, i, prefix, opts.Suffix, comments)
+		if !found {
+			continue
+		}
+		if reasons[i] != "" {
+			skipped = append(skipped, Skipped{LineNumber: lineNumber, Directive: reasons[i]})
 			continue
 		}
 
-		def := new(Definition)
-		totalCount += 1
-
-		err := json.Unmarshal([]byte(jsonStr), def)
+		def, err := decodeDefinition(jsonStr, opts.AllowUnknownFields)
 		if err != nil {
-			return Result{}, xerrors.Errorf("%d: Unmarsharing `%s` as JSON has been failed, check the given prefix: %w", lineNumber, jsonStr, err)
+			return Result{}, &LineError{Line: lineNumber, Err: err}
+		}
+		if opts.Filter != nil && !opts.Filter(lineNumber, def) {
+			continue
 		}
+
+		totalCount += 1
 		extractor, err := regexp.Compile(def.Extract)
 		if err != nil {
-			return Result{}, xerrors.Errorf("%d: Invalid regex `%s`: %w", lineNumber, def.Extract, err)
+			return Result{}, &LineError{Line: lineNumber, Err: xerrors.Errorf("Invalid regex `%s`: %w", def.Extract, err)}
+		}
+		isStructured := def.KeyPath != "" || def.Extract == ""
+		if isStructured && opts.Format == nil {
+			return Result{}, &LineError{Line: lineNumber, Err: xerrors.New("`extract` is missing, or `key` is used in a file that is not YAML, TOML or JSON")}
 		}
 		if len(def.Command) < 1 {
-			return Result{}, xerrors.Errorf("%d: Given JSON `%s` does not include commands", lineNumber, jsonStr)
+			return Result{}, &LineError{Line: lineNumber, Err: xerrors.Errorf("Given JSON `%s` does not include commands", jsonStr)}
+		}
+		cmd, err := opts.command(def)
+		if err != nil {
+			return Result{}, &LineError{Line: lineNumber, Err: err}
 		}
-		cmd := def.Command[0]
-		args := def.Command[1:]
-		out, err := exec.Command(cmd, args...).Output()
+		replacer, err := resolver.Resolve(def, cmd)
 		if err != nil {
-			return Result{}, xerrors.Errorf("%d: Executing %s has been failed: %w", lineNumber, cmd, err)
-		}
-		cmdResult := strings.TrimSuffix(string(out), "\n")
-		replacer := cmdResult
-		if def.Nth > 0 {
-			var fields []string
-			if def.Delimiter == "" {
-				fields = strings.Fields(cmdResult)
-			} else {
-				fields = strings.Split(cmdResult, def.Delimiter)
+			return Result{}, &LineError{Line: lineNumber, Err: err}
+		}
+		annotationStart := len(headWithVersion) + len(separator)
+		annotationBytes := Span{Start: annotationStart, End: annotationStart + len(jsonStr)}
+		annotationRunes := runeSpan(line, annotationStart, annotationStart+len(jsonStr))
+		if isStructured {
+			if scalars == nil {
+				scalars, err = opts.Format.Scalars(lines)
+				if err != nil {
+					return Result{}, &LineError{Line: lineNumber, Err: err}
+				}
+			}
+			target, newLine, err := editScalar(lines, scalars, i, def, extractor, replacer)
+			if err != nil {
+				return Result{}, &LineError{Line: lineNumber, Err: err}
+			}
+			if by, ok := updatedBy[target.LineNumber-1]; ok {
+				return Result{}, &LineError{Line: lineNumber, Err: xerrors.Errorf("The value in line %d is already updated by line %d", target.LineNumber, by)}
 			}
-			if def.Nth > len(fields) {
-				return Result{}, xerrors.Errorf("%d: Accessing invalid fields: STDOUT:%s Delimiter:%s Nth:%d", lineNumber, cmdResult, def.Delimiter, def.Nth)
+			updatedBy[target.LineNumber-1] = lineNumber
+			newLines[target.LineNumber-1] = newLine
+			target.AnnotationLineNumber = lineNumber
+			target.AnnotationBytes = annotationBytes
+			target.AnnotationRunes = annotationRunes
+			if target.IsChanged {
+				changedCount++
 			}
-			index := def.Nth - 1
-			replacer = fields[index]
+			targets = append(targets, target)
+			continue
+		}
+		// Inserts the replacer at the head if nothing is extracted, then it fails in the following check
+		location := extractor.FindStringIndex(headWithVersion)
+		if location == nil {
+			location = []int{0, 0}
 		}
-		extracted := extractor.FindString(headWithVersion)
-		replaced := strings.Replace(headWithVersion, extracted, replacer, 1)
+		extracted := headWithVersion[location[0]:location[1]]
+		replaced := headWithVersion[:location[0]] + replacer + headWithVersion[location[1]:]
 		isChanged := false
 		extractedToEnsure := extractor.FindString(replaced)
 		if replacer != extractedToEnsure {
-			return Result{}, xerrors.Errorf("%d: The result of updater command has malformed format: %s", lineNumber, replacer)
+			return Result{}, &LineError{Line: lineNumber, Err: xerrors.Errorf("The result of updater command has malformed format: %s", replacer)}
 		}
+		if by, ok := updatedBy[i]; ok {
+			return Result{}, &LineError{Line: lineNumber, Err: xerrors.Errorf("The value in line %d is already updated by line %d", lineNumber, by)}
+		}
+		updatedBy[i] = lineNumber
 		if replaced != headWithVersion {
 			isChanged = true
 			changedCount++
 		}
-		newLines = append(newLines, replaced+separator+jsonStr)
+		newLines[i] = replaced + line[len(headWithVersion):]
 		targets = append(targets, Target{
-			LineNumber: lineNumber,
-			Extracted:  extracted,
-			Replacer:   replacer,
-			IsChanged:  isChanged,
+			LineNumber:           lineNumber,
+			AnnotationLineNumber: lineNumber,
+			Extracted:            extracted,
+			Replacer:             replacer,
+			IsChanged:            isChanged,
+			ID:                   def.ID,
+			Command:              def.Command,
+			ValueBytes:           Span{Start: location[0], End: location[1]},
+			ValueRunes:           runeSpan(line, location[0], location[1]),
+			AnnotationBytes:      annotationBytes,
+			AnnotationRunes:      annotationRunes,
 		})
 	}
 
-	err := scanner.Err()
-	if err != nil {
-		return Result{}, err
-	}
-
 	return Result{
 		NewLines:     newLines,
 		Targets:      targets,
 		Total:        totalCount,
 		ChangedCount: changedCount,
+		Skipped:      skipped,
 	}, nil
 }
@@ -1,15 +1,28 @@
 package runner
 
 import (
+	"os/exec"
 	"regexp"
 	"strings"
 	"testing"
 
 	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+	"github.com/kachick/selfup/internal/syntax"
+	"golang.org/x/xerrors"
 )
 
 const defaultPrefix string = "\\s*[#;/]* selfup "
 
+// Emulates echo to avoid depending on the executables in PATH
+var echoExecutor = ExecutorFunc(func(cmd Command) ([]byte, error) {
+	if cmd.Argv[0] != "echo" {
+		return nil, exec.ErrNotFound
+	}
+
+	return []byte(strings.Join(cmd.Argv[1:], " ") + "\n"), nil
+})
+
 func TestDryRun(t *testing.T) {
 	type testCase struct {
 		input  string
@@ -38,9 +51,9 @@ not_be_replacedB: ':<' # selfup { "extract": ":[<\\)]", "replacer": ["echo", ":)
 					`not_be_replacedB: ':)' # selfup { "extract": ":[<\\)]", "replacer": ["echo", ":)"] }`,
 				},
 				Targets: []Target{
-					{LineNumber: 2, Extracted: "0.39.0", Replacer: "0.76.9", IsChanged: true},
-					{LineNumber: 3, Extracted: "0.39.0", Replacer: "0.39.0"},
-					{LineNumber: 5, Extracted: ":<", Replacer: ":)", IsChanged: true},
+					{LineNumber: 2, AnnotationLineNumber: 2, Extracted: "0.39.0", Replacer: "0.76.9", IsChanged: true, Command: []string{"echo", "0.76.9"}},
+					{LineNumber: 3, AnnotationLineNumber: 3, Extracted: "0.39.0", Replacer: "0.39.0", Command: []string{"echo", "0.39.0"}},
+					{LineNumber: 5, AnnotationLineNumber: 5, Extracted: ":<", Replacer: ":)", IsChanged: true, Command: []string{"echo", ":)"}},
 				},
 				ChangedCount: 2,
 				Total:        3,
@@ -61,7 +74,7 @@ not_be_replacedA: '0.39.0' # selfup { "extract": "\\d[^']+", "replacer": ["echo"
 					`not_be_replacedA: '0.39.0' # selfup { "extract": "\\d[^']+", "replacer": ["echo", "0.76.9"] }`,
 				},
 				Targets: []Target{
-					{LineNumber: 2, Extracted: "0.39.0", Replacer: "0.76.9", IsChanged: true},
+					{LineNumber: 2, AnnotationLineNumber: 2, Extracted: "0.39.0", Replacer: "0.76.9", IsChanged: true, Command: []string{"echo", "0.76.9"}},
 				},
 				ChangedCount: 1,
 				Total:        1,
@@ -83,9 +96,9 @@ not_be_replacedA: 0.39.0 # selfup { "extract": "\\b[0-9.]+", "replacer": ["echo"
 					`not_be_replacedA: 0.39.0 # selfup { "extract": "\\b[0-9.]+", "replacer": ["echo", "0.39.0"] }`,
 				},
 				Targets: []Target{
-					{LineNumber: 2, Extracted: "0.39.0", Replacer: "0.76.9", IsChanged: true},
-					{LineNumber: 3, Extracted: "0.39.0", Replacer: "0.76.9", IsChanged: true},
-					{LineNumber: 4, Extracted: "0.39.0", Replacer: "0.39.0", IsChanged: false},
+					{LineNumber: 2, AnnotationLineNumber: 2, Extracted: "0.39.0", Replacer: "0.76.9", IsChanged: true, Command: []string{"echo", "0.76.9"}},
+					{LineNumber: 3, AnnotationLineNumber: 3, Extracted: "0.39.0", Replacer: "0.76.9", IsChanged: true, Command: []string{"echo", "0.76.9"}},
+					{LineNumber: 4, AnnotationLineNumber: 4, Extracted: "0.39.0", Replacer: "0.39.0", IsChanged: false, Command: []string{"echo", "0.39.0"}},
 				},
 				ChangedCount: 2,
 				Total:        3,
@@ -105,7 +118,7 @@ not_be_replacedA: '0.39.0' # selfup { "extract": "\\d[^']+", "replacer": ["echo"
 					`not_be_replacedA: '0.39.0' # selfup { "extract": "\\d[^']+", "replacer": ["echo", "0.76.9"] }`,
 				},
 				Targets: []Target{
-					{LineNumber: 2, Extracted: "0.39.0", Replacer: "0.76.9", IsChanged: true},
+					{LineNumber: 2, AnnotationLineNumber: 2, Extracted: "0.39.0", Replacer: "0.76.9", IsChanged: true, Command: []string{"echo", "0.76.9"}},
 				},
 				ChangedCount: 1,
 				Total:        1,
@@ -121,7 +134,7 @@ not_be_replacedA: '0.39.0' # selfup { "extract": "\\d[^']+", "replacer": ["echo"
 					`will_be_replaced: '0.76.9' # selfup { "extract": "\\d[^']+", "replacer": ["echo", "    supertool  0.76.9  "], "nth": 2 }`,
 				},
 				Targets: []Target{
-					{LineNumber: 1, Extracted: "0.39.0", Replacer: "0.76.9", IsChanged: true},
+					{LineNumber: 1, AnnotationLineNumber: 1, Extracted: "0.39.0", Replacer: "0.76.9", IsChanged: true, Command: []string{"echo", "    supertool  0.76.9  "}},
 				},
 				ChangedCount: 1,
 				Total:        1,
@@ -137,7 +150,23 @@ not_be_replacedA: '0.39.0' # selfup { "extract": "\\d[^']+", "replacer": ["echo"
 					`will_be_replaced: '0.76.9' # selfup { "extract": "\\d[^']+", "replacer": ["echo", "supertool:0.76.9"], "nth": 2, "delimiter": ":" }`,
 				},
 				Targets: []Target{
-					{LineNumber: 1, Extracted: "0.39.0", Replacer: "0.76.9", IsChanged: true},
+					{LineNumber: 1, AnnotationLineNumber: 1, Extracted: "0.39.0", Replacer: "0.76.9", IsChanged: true, Command: []string{"echo", "supertool:0.76.9"}},
+				},
+				ChangedCount: 1,
+				Total:        1,
+			},
+		}, "Multibyte characters": {
+			input: `名前: '0.39.0' # selfup { "extract": "\\d[^']+", "replacer": ["echo", "0.76.9"] }
+`,
+			prefix: defaultPrefix,
+			skipBy: "",
+			ok:     true,
+			want: Result{
+				NewLines: []string{
+					`名前: '0.76.9' # selfup { "extract": "\\d[^']+", "replacer": ["echo", "0.76.9"] }`,
+				},
+				Targets: []Target{
+					{LineNumber: 1, AnnotationLineNumber: 1, Extracted: "0.39.0", Replacer: "0.76.9", IsChanged: true, Command: []string{"echo", "0.76.9"}},
 				},
 				ChangedCount: 1,
 				Total:        1,
@@ -207,7 +236,7 @@ broken: ':<' # selfup {{ """" }
 	for what, tc := range testCases {
 		t.Run(what, func(t *testing.T) {
 			prefix := regexp.MustCompile(tc.prefix)
-			result, err := DryRun(strings.NewReader(tc.input), prefix, tc.skipBy)
+			result, err := DryRunWith(strings.NewReader(tc.input), Options{Prefix: prefix, SkipBy: tc.skipBy, Executor: echoExecutor})
 			if err != nil {
 				if tc.ok {
 					t.Fatalf("unexpected error happened: %v", err)
@@ -219,9 +248,194 @@ broken: ':<' # selfup {{ """" }
 				t.Fatalf("expected error did not happen")
 			}
 
-			if diff := cmp.Diff(tc.want, result); diff != "" {
+			// Positions are tested in TestDryRunPositions
+			if diff := cmp.Diff(tc.want, result, cmpopts.IgnoreFields(Target{}, "ValueBytes", "ValueRunes", "AnnotationBytes", "AnnotationRunes")); diff != "" {
+				t.Errorf("wrong result: %s", diff)
+			}
+		})
+	}
+}
+
+func TestDryRunPositions(t *testing.T) {
+	type testCase struct {
+		input string
+		want  Target
+	}
+	testCases := map[string]testCase{
+		"ASCII": {
+			input: `version: '0.39.0' # selfup { "extract": "\\d[^']+", "replacer": ["echo", "0.76.9"] }`,
+			want: Target{
+				ValueBytes:      Span{Start: 10, End: 16},
+				ValueRunes:      Span{Start: 10, End: 16},
+				AnnotationBytes: Span{Start: 27, End: 84},
+				AnnotationRunes: Span{Start: 27, End: 84},
+			},
+		},
+		"Multibyte characters": {
+			input: `名前: '0.39.0' # selfup { "extract": "\\d[^']+", "replacer": ["echo", "0.76.9"] }`,
+			want: Target{
+				ValueBytes:      Span{Start: 9, End: 15},
+				ValueRunes:      Span{Start: 5, End: 11},
+				AnnotationBytes: Span{Start: 26, End: 83},
+				AnnotationRunes: Span{Start: 22, End: 79},
+			},
+		},
+		"Value after the same text": {
+			input: `ver=a0.39.0 0.39.0 # selfup { "extract": "\\b\\d+\\.\\d+\\.\\d+", "replacer": ["echo", "0.76.9"] }`,
+			want: Target{
+				ValueBytes:      Span{Start: 12, End: 18},
+				ValueRunes:      Span{Start: 12, End: 18},
+				AnnotationBytes: Span{Start: 28, End: 98},
+				AnnotationRunes: Span{Start: 28, End: 98},
+			},
+		},
+	}
+
+	for what, tc := range testCases {
+		t.Run(what, func(t *testing.T) {
+			prefix := regexp.MustCompile(defaultPrefix)
+			result, err := DryRunWith(strings.NewReader(tc.input), Options{Prefix: prefix, Executor: echoExecutor})
+			if err != nil {
+				t.Fatalf("unexpected error happened: %v", err)
+			}
+			if len(result.Targets) != 1 {
+				t.Fatalf("wrong targets: %v", result.Targets)
+			}
+			got := result.Targets[0]
+			if diff := cmp.Diff(tc.want, got, cmpopts.IgnoreFields(Target{}, "LineNumber", "AnnotationLineNumber", "Extracted", "Replacer", "IsChanged", "ID", "Command")); diff != "" {
 				t.Errorf("wrong result: %s", diff)
 			}
+			line := []rune(tc.input)
+			if string(line[got.ValueRunes.Start:got.ValueRunes.End]) != got.Extracted {
+				t.Errorf("ValueRunes does not point the extracted value: %v", got.ValueRunes)
+			}
+			if tc.input[got.ValueBytes.Start:got.ValueBytes.End] != got.Extracted {
+				t.Errorf("ValueBytes does not point the extracted value: %v", got.ValueBytes)
+			}
+			if result.NewLines[0][:got.ValueBytes.Start] != tc.input[:got.ValueBytes.Start] {
+				t.Errorf("replaced another position: %s", result.NewLines[0])
+			}
+			if tc.input[got.AnnotationBytes.Start] != '{' {
+				t.Errorf("AnnotationBytes does not point the JSON: %v", got.AnnotationBytes)
+			}
 		})
 	}
 }
+
+func TestDefinitionKey(t *testing.T) {
+	withID := Definition{ID: "supertool", Command: []string{"echo", "0.76.9"}}
+	if withID.Key() != "supertool" {
+		t.Errorf("expected the ID is used as the key, got %s", withID.Key())
+	}
+
+	a := Definition{Extract: "\\d+", Command: []string{"echo", "supertool 0.76.9"}}
+	b := Definition{Extract: "[0-9.]+", Command: []string{"echo", "supertool 0.76.9"}}
+	c := Definition{Extract: "\\d+", Command: []string{"echo", "supertool 0.76.9"}, Nth: 2}
+	if a.Key() != b.Key() {
+		t.Errorf("expected the extract does not affect the key: %s, %s", a.Key(), b.Key())
+	}
+	if a.Key() == c.Key() {
+		t.Errorf("expected the nth affects the key: %s", a.Key())
+	}
+}
+
+func TestDryRunLineError(t *testing.T) {
+	input := `Header
+broken: ':<' # selfup { "extract": ":[<\\)]", "replacer": ["this_command_does_not_exist"] }
+`
+	prefix := regexp.MustCompile(defaultPrefix)
+	_, err := DryRunWith(strings.NewReader(input), Options{Prefix: prefix, Executor: echoExecutor})
+	if err == nil {
+		t.Fatalf("expected error did not happen")
+	}
+	var lineErr *LineError
+	if !xerrors.As(err, &lineErr) {
+		t.Fatalf("expected LineError, got %T", err)
+	}
+	if lineErr.Line != 2 {
+		t.Errorf("wrong line: %d", lineErr.Line)
+	}
+	if !xerrors.Is(err, exec.ErrNotFound) {
+		t.Errorf("expected the cause is kept: %v", err)
+	}
+	if !strings.HasPrefix(err.Error(), "2: Executing this_command_does_not_exist has been failed") {
+		t.Errorf("wrong message: %s", err.Error())
+	}
+}
+
+func TestDryRunFilter(t *testing.T) {
+	input := `dprint: '0.39.0' # selfup { "extract": "\\d[^']+", "replacer": ["echo", "0.40.2"] }
+typos: '1.10.9' # selfup { "extract": "\\d[^']+", "replacer": ["do_not_run_this", "1.16.0"] }
+`
+	prefix := regexp.MustCompile(defaultPrefix)
+	result, err := DryRunWith(strings.NewReader(input), Options{
+		Prefix:   prefix,
+		Executor: echoExecutor,
+		Filter: func(lineNumber int, def Definition) bool {
+			return def.Command[0] == "echo"
+		},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error happened: %v", err)
+	}
+
+	want := []string{
+		`dprint: '0.40.2' # selfup { "extract": "\\d[^']+", "replacer": ["echo", "0.40.2"] }`,
+		`typos: '1.10.9' # selfup { "extract": "\\d[^']+", "replacer": ["do_not_run_this", "1.16.0"] }`,
+	}
+	if diff := cmp.Diff(want, result.NewLines); diff != "" {
+		t.Errorf("wrong result: %s", diff)
+	}
+	if result.Total != 1 || len(result.Targets) != 1 || result.Targets[0].LineNumber != 1 {
+		t.Errorf("expected the filtered definitions are not counted: %v", result)
+	}
+}
+
+func TestDryRunSuffix(t *testing.T) {
+	input := `<p>0.39.0</p> <!-- selfup { "extract": "\\d[^<]+", "replacer": ["echo", "0.76.9"] } -->
+0.39.0 /* selfup { "extract": "\\d[^ ]+", "replacer": ["echo", "0.76.9"] } */
+`
+	result, err := DryRunWith(strings.NewReader(input), Options{
+		Prefix:   regexp.MustCompile(`(?:\s*<!-- selfup )|(?:\s*/\* selfup )`),
+		Suffix:   regexp.MustCompile(`\s*(?:-->|\*/)`),
+		Executor: echoExecutor,
+	})
+	if err != nil {
+		t.Fatalf("unexpected error happened: %v", err)
+	}
+
+	want := []string{
+		`<p>0.76.9</p> <!-- selfup { "extract": "\\d[^<]+", "replacer": ["echo", "0.76.9"] } -->`,
+		`0.76.9 /* selfup { "extract": "\\d[^ ]+", "replacer": ["echo", "0.76.9"] } */`,
+	}
+	if diff := cmp.Diff(want, result.NewLines); diff != "" {
+		t.Errorf("wrong result: %s", diff)
+	}
+	if len(result.Targets) != 2 || result.Targets[0].AnnotationBytes != (Span{Start: 26, End: 83}) {
+		t.Errorf("expected the annotation does not include the suffix: %v", result.Targets)
+	}
+}
+
+func TestDryRunLanguage(t *testing.T) {
+	input := "const version = \"0.39.0\" // selfup { \"extract\": \"\\\\d[^\\\"]+\", \"replacer\": [\"echo\", \"0.76.9\"] }\n" +
+		"const annotation = `0.39.0 // selfup { \"extract\": \"\\\\d[^ ]+\", \"replacer\": [\"do_not_run_this\"] }`\n"
+	result, err := DryRunWith(strings.NewReader(input), Options{
+		Prefix:   regexp.MustCompile(defaultPrefix),
+		Language: syntax.Go,
+		Executor: echoExecutor,
+	})
+	if err != nil {
+		t.Fatalf("unexpected error happened: %v", err)
+	}
+
+	want := []string{
+		"const version = \"0.76.9\" // selfup { \"extract\": \"\\\\d[^\\\"]+\", \"replacer\": [\"echo\", \"0.76.9\"] }",
+		"const annotation = `0.39.0 // selfup { \"extract\": \"\\\\d[^ ]+\", \"replacer\": [\"do_not_run_this\"] }`",
+	}
+	if diff := cmp.Diff(want, result.NewLines); diff != "" {
+		t.Errorf("wrong result: %s", diff)
+	}
+	if result.Total != 1 {
+		t.Errorf("expected the definition in the string literal is not counted: %v", result)
+	}
+}
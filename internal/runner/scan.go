@@ -0,0 +1,56 @@
+package runner
+
+import (
+	"io"
+	"regexp"
+	"strings"
+
+	"github.com/kachick/selfup/internal/syntax"
+)
+
+// Annotation is a parsed definition with the line number
+type Annotation struct {
+	LineNumber int
+	Definition Definition
+}
+
+// Scan parses the definitions without executing replacers.
+// The language is optional to handle only the definitions in comments.
+func Scan(r io.Reader, prefix *regexp.Regexp, suffix *regexp.Regexp, language *syntax.Language, skipBy string, allowUnknownFields bool) ([]Annotation, error) {
+	annotations := []Annotation{}
+
+	lines, err := readLines(r)
+	if err != nil {
+		return nil, err
+	}
+	reasons := skippedBy(lines)
+	comments := commentsOf(language, lines)
+	for i, line := range lines {
+		lineNumber := i + 1
+		if (skipBy != "" && strings.Contains(line, skipBy)) || reasons[i] != "" {
+			continue
+		}
+		_, _, jsonStr, found := splitInComment(lines, i, prefix, suffix, comments)
+		if !found {
+			continue
+		}
+
+		def, err := decodeDefinition(jsonStr, allowUnknownFields)
+		if err != nil {
+			return nil, &LineError{Line: lineNumber, Err: err}
+		}
+		annotations = append(annotations, Annotation{LineNumber: lineNumber, Definition: def})
+	}
+
+	return annotations, nil
+}
+
+// AnnotationOffset returns the byte offset of the definition JSON in the line
+func AnnotationOffset(line string, prefix *regexp.Regexp) (int, bool) {
+	head, separator, _, found := split(line, prefix, nil)
+	if !found {
+		return 0, false
+	}
+
+	return len(head) + len(separator), true
+}
@@ -0,0 +1,48 @@
+package runner
+
+import (
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestScan(t *testing.T) {
+	input := `Header
+will_be_replaced: '0.39.0' # selfup { "extract": "\\d[^']+", "replacer": ["this_command_is_not_executed"] }
+skipped: '0.39.0' # selfup { "extract": "\\d[^']+", "replacer": ["do_not_run_this"] }
+with_fields: '0.39.0' # selfup { "extract": "\\d[^']+", "replacer": ["supertool", "--version"], "nth": 2, "cwd": "." }
+`
+	annotations, err := Scan(strings.NewReader(input), regexp.MustCompile(defaultPrefix), nil, nil, "skipped", false)
+	if err != nil {
+		t.Fatalf("unexpected error happened: %v", err)
+	}
+
+	want := []Annotation{
+		{LineNumber: 2, Definition: Definition{Extract: "\\d[^']+", Command: []string{"this_command_is_not_executed"}}},
+		{LineNumber: 4, Definition: Definition{Extract: "\\d[^']+", Command: []string{"supertool", "--version"}, Nth: 2, Cwd: "."}},
+	}
+	if diff := cmp.Diff(want, annotations); diff != "" {
+		t.Errorf("wrong result: %s", diff)
+	}
+
+	_, err = Scan(strings.NewReader(`broken: ':<' # selfup {{ """" }`), regexp.MustCompile(defaultPrefix), nil, nil, "", false)
+	if err == nil {
+		t.Fatalf("expected error did not happen")
+	}
+}
+
+func TestAnnotationOffset(t *testing.T) {
+	prefix := regexp.MustCompile(defaultPrefix)
+
+	offset, ok := AnnotationOffset(`version: '0.39.0' # selfup { "extract": "\\d[^']+" }`, prefix)
+	if !ok || offset != 27 {
+		t.Errorf("wrong offset: %d, %t", offset, ok)
+	}
+
+	_, ok = AnnotationOffset(`# selfup is a tool to update versions`, prefix)
+	if ok {
+		t.Errorf("expected no annotation in the line")
+	}
+}
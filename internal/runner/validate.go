@@ -0,0 +1,310 @@
+package runner
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"slices"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/kachick/selfup/internal/structured"
+	"github.com/kachick/selfup/internal/syntax"
+	"golang.org/x/xerrors"
+)
+
+type Problem struct {
+	Line int
+	// Counted in characters(runes) from 1
+	Column  int
+	Message string
+}
+
+func (p Problem) String() string {
+	return fmt.Sprintf("%d:%d: %s", p.Line, p.Column, p.Message)
+}
+
+// Returns JSON keys and the Go types of Definition
+func definitionFields() map[string]reflect.Type {
+	fields := map[string]reflect.Type{}
+	typ := reflect.TypeFor[Definition]()
+	for i := range typ.NumField() {
+		field := typ.Field(i)
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		fields[name] = field.Type
+	}
+
+	return fields
+}
+
+func jsonTypeName(typ reflect.Type) string {
+	switch typ.Kind() {
+	case reflect.String:
+		return "string"
+	case reflect.Int:
+		return "number"
+	case reflect.Slice:
+		return "array of " + jsonTypeName(typ.Elem()) + "s"
+	case reflect.Pointer:
+		return jsonTypeName(typ.Elem())
+	case reflect.Struct, reflect.Map:
+		return "object"
+	default:
+		return typ.String()
+	}
+}
+
+type member struct {
+	key         string
+	keyOffset   int
+	value       json.RawMessage
+	valueOffset int
+}
+
+// Returns the byte offset of the next token, InputOffset points the end of the previous token
+func nextTokenOffset(jsonStr string, offset int64) int {
+	i := int(offset)
+	for i < len(jsonStr) && strings.ContainsRune(" \t\r\n,:", rune(jsonStr[i])) {
+		i++
+	}
+
+	return i
+}
+
+// Splits a JSON object into members with the byte offsets of the keys and values
+func objectMembers(jsonStr string) ([]member, error) {
+	dec := json.NewDecoder(strings.NewReader(jsonStr))
+	token, err := dec.Token()
+	if err != nil {
+		return nil, err
+	}
+	if token != json.Delim('{') {
+		return nil, xerrors.New("JSON should be an object")
+	}
+
+	members := []member{}
+	for dec.More() {
+		keyOffset := nextTokenOffset(jsonStr, dec.InputOffset())
+		token, err := dec.Token()
+		if err != nil {
+			return nil, err
+		}
+		key, ok := token.(string)
+		if !ok {
+			return nil, xerrors.Errorf("Unexpected token `%v`", token)
+		}
+		valueOffset := nextTokenOffset(jsonStr, dec.InputOffset())
+		var value json.RawMessage
+		err = dec.Decode(&value)
+		if err != nil {
+			return nil, err
+		}
+		members = append(members, member{key: key, keyOffset: keyOffset, value: value, valueOffset: valueOffset})
+	}
+	_, err = dec.Token()
+	if err != nil {
+		return nil, err
+	}
+
+	return members, nil
+}
+
+// Returns the byte offset of the JSON syntax error if possible
+func syntaxErrorOffset(err error) (int, bool) {
+	var syntaxErr *json.SyntaxError
+	if xerrors.As(err, &syntaxErr) {
+		return int(syntaxErr.Offset), true
+	}
+
+	return 0, false
+}
+
+// The locate is nil if the file format does not support `key`
+func (opts Options) validateDefinition(head string, jsonStr string, jsonColumn int, locate func(key string) error) []Problem {
+	problems := []Problem{}
+	report := func(offset int, format string, a ...any) {
+		problems = append(problems, Problem{Column: jsonColumn + offset, Message: fmt.Sprintf(format, a...)})
+	}
+
+	members, err := objectMembers(jsonStr)
+	if err != nil {
+		offset, _ := syntaxErrorOffset(err)
+		report(offset, "Invalid JSON: %v", err)
+		return problems
+	}
+
+	fields := definitionFields()
+	seen := map[string]bool{}
+	offsets := map[string]int{}
+	valid := map[string]json.RawMessage{}
+	for _, m := range members {
+		typ, ok := fields[m.key]
+		if !ok {
+			// Beta schema is always reported as in decodeDefinition
+			if !opts.AllowUnknownFields || slices.Contains(betaFields, m.key) {
+				report(m.keyOffset, "%s", unknownFieldMessage(m.key))
+			}
+			continue
+		}
+		if seen[m.key] {
+			report(m.keyOffset, "Duplicated field `%s`", m.key)
+			continue
+		}
+		seen[m.key] = true
+		dec := json.NewDecoder(bytes.NewReader(m.value))
+		if !opts.AllowUnknownFields {
+			dec.DisallowUnknownFields()
+		}
+		err := dec.Decode(reflect.New(typ).Interface())
+		var typeErr *json.UnmarshalTypeError
+		if (err != nil && xerrors.As(err, &typeErr)) || bytes.Equal(m.value, []byte("null")) {
+			report(m.valueOffset, "`%s` should be %s, but given `%s`", m.key, jsonTypeName(typ), m.value)
+			continue
+		}
+		if err != nil {
+			report(m.valueOffset, "Invalid `%s`: %v", m.key, err)
+			continue
+		}
+		offsets[m.key] = m.valueOffset
+		valid[m.key] = m.value
+	}
+	def := Definition{}
+	// Only includes type checked members, so this should not fail
+	validJSON, _ := json.Marshal(valid)
+	_ = json.Unmarshal(validJSON, &def)
+
+	if extractOffset, ok := offsets["extract"]; ok {
+		extractor, err := regexp.Compile(def.Extract)
+		switch {
+		case def.Extract == "":
+			report(extractOffset, "`extract` is empty")
+		case err != nil:
+			report(extractOffset, "Invalid regex `%s`: %v", def.Extract, err)
+		case !seen["key"] && !extractor.MatchString(head):
+			problems = append(problems, Problem{Column: 1, Message: fmt.Sprintf("`extract` does not match the current line: %s", def.Extract)})
+		}
+	} else if !seen["extract"] && locate == nil {
+		report(0, "`extract` is missing")
+	} else if !seen["extract"] && !seen["key"] {
+		if err := locate(""); err != nil {
+			report(0, "%v", err)
+		}
+	}
+
+	if keyOffset, ok := offsets["key"]; ok {
+		switch {
+		case def.KeyPath == "":
+			report(keyOffset, "`key` is empty")
+		case locate == nil:
+			report(keyOffset, "`key` is available only in YAML, TOML and JSON files")
+		default:
+			if err := locate(def.KeyPath); err != nil {
+				report(keyOffset, "%v", err)
+			}
+		}
+	}
+
+	if replacerOffset, ok := offsets["replacer"]; ok {
+		if len(def.Command) < 1 {
+			report(replacerOffset, "`replacer` is empty")
+		} else if err := opts.lookPath(def); err != nil {
+			report(replacerOffset, "%v", err)
+		}
+	} else if !seen["replacer"] {
+		report(0, "`replacer` is missing")
+	}
+
+	if nthOffset, ok := offsets["nth"]; ok && def.Nth < 0 {
+		report(nthOffset, "`nth` should not be negative, but given %d", def.Nth)
+	}
+
+	return problems
+}
+
+// Relative paths like "./scripts/version.sh" are looked up in the cwd of the definition as executed in run
+func (opts Options) lookPath(def Definition) error {
+	name := def.Command[0]
+	if !strings.ContainsRune(name, '/') || filepath.IsAbs(name) {
+		_, err := exec.LookPath(name)
+		if err != nil {
+			return xerrors.Errorf("Executable `%s` is not found in PATH", name)
+		}
+		return nil
+	}
+
+	dir := resolveDir(def.Cwd, opts.Dir, opts.Root)
+	path := filepath.FromSlash(name)
+	if dir != "" {
+		path = filepath.Join(dir, path)
+	}
+	// LookPath searches PATH for names without separators, like "version.sh" joined to "."
+	if !strings.ContainsRune(path, filepath.Separator) {
+		path = "." + string(filepath.Separator) + path
+	}
+	_, err := exec.LookPath(path)
+	if err != nil {
+		if dir == "" {
+			return xerrors.Errorf("Executable `%s` is not found in the working directory", name)
+		}
+		return xerrors.Errorf("Executable `%s` is not found in `%s`", name, dir)
+	}
+
+	return nil
+}
+
+// Validate checks the definitions without executing replacers and reports all found problems.
+// The language is optional to check only the definitions in comments.
+func Validate(r io.Reader, prefix *regexp.Regexp, suffix *regexp.Regexp, language *syntax.Language, skipBy string) ([]Problem, error) {
+	return ValidateWith(r, Options{Prefix: prefix, Suffix: suffix, Language: language, SkipBy: skipBy})
+}
+
+// ValidateWith is Validate with the options of DryRunWith, the Format is used to check `key`
+func ValidateWith(r io.Reader, opts Options) ([]Problem, error) {
+	problems := []Problem{}
+
+	lines, err := readLines(r)
+	if err != nil {
+		return nil, err
+	}
+	reasons := skippedBy(lines)
+	comments := commentsOf(opts.Language, lines)
+	var scalars []structured.Scalar
+	for i, line := range lines {
+		lineNumber := i + 1
+		if (opts.SkipBy != "" && strings.Contains(line, opts.SkipBy)) || reasons[i] != "" {
+			continue
+		}
+		head, separator, jsonStr, found := splitInComment(lines, i, opts.Prefix, opts.Suffix, comments)
+		if !found {
+			continue
+		}
+
+		var locate func(key string) error
+		if opts.Format != nil {
+			locate = func(key string) error {
+				if scalars == nil {
+					scalars, err = opts.Format.Scalars(lines)
+					if err != nil {
+						return err
+					}
+				}
+				_, err := locateScalar(scalars, key, i)
+				return err
+			}
+		}
+		jsonColumn := len(head) + len(separator) + 1
+		for _, p := range opts.validateDefinition(head, jsonStr, jsonColumn, locate) {
+			p.Line = lineNumber
+			// Columns are reported in bytes until here
+			p.Column = utf8.RuneCountInString(line[:min(p.Column-1, len(line))]) + 1
+			problems = append(problems, p)
+		}
+	}
+
+	return problems, nil
+}
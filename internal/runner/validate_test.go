@@ -0,0 +1,170 @@
+package runner
+
+import (
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/kachick/selfup/internal/structured"
+)
+
+func TestValidate(t *testing.T) {
+	type testCase struct {
+		input              string
+		skipBy             string
+		format             structured.Format
+		allowUnknownFields bool
+		want               []Problem
+	}
+	testCases := map[string]testCase{
+		"Valid": {
+			input: `Header
+valid: '0.39.0' # selfup { "extract": "\\d[^']+", "replacer": ["echo", "0.76.9"], "nth": 1, "delimiter": ":", "id": "tool" }
+No JSON in this line
+`,
+			want: []Problem{},
+		},
+		"Unknown fields": {
+			input: `typo: '0.39.0' # selfup { "extract": "\\d[^']+", "replacr": ["echo", "0.76.9"] }
+`,
+			want: []Problem{
+				{Line: 1, Column: 50, Message: "Unknown field `replacr`, did you mean `replacer`?"},
+				{Line: 1, Column: 25, Message: "`replacer` is missing"},
+			},
+		},
+		"Allowed unknown fields": {
+			input: `typo: '0.39.0' # selfup { "extract": "\\d[^']+", "replacer": ["echo", "0.76.9"], "comment": "ok", "env": { "note": "ok" } }
+beta: '0.39.0' # selfup { "regex": "\\d[^']+", "script": "echo 0.76.9" }
+`,
+			allowUnknownFields: true,
+			want: []Problem{
+				{Line: 2, Column: 27, Message: "Unknown field `regex` is a key of beta schema, run `selfup migrate` to convert it into v1 schema"},
+				{Line: 2, Column: 48, Message: "Unknown field `script` is a key of beta schema, run `selfup migrate` to convert it into v1 schema"},
+				{Line: 2, Column: 25, Message: "`extract` is missing"},
+				{Line: 2, Column: 25, Message: "`replacer` is missing"},
+			},
+		},
+		"Wrong types and negative nth": {
+			input: `wrong: '0.39.0' # selfup { "extract": 42, "replacer": "echo", "nth": -1 }
+`,
+			want: []Problem{
+				{Line: 1, Column: 39, Message: "`extract` should be string, but given `42`"},
+				{Line: 1, Column: 55, Message: "`replacer` should be array of strings, but given `\"echo\"`"},
+				{Line: 1, Column: 70, Message: "`nth` should not be negative, but given -1"},
+			},
+		},
+		"Columns in characters": {
+			input: `名前: '0.39.0' # selfup { "extract": "\\d[^']+", "replacer": ["echo", "0.76.9"], "nth": -1 }
+`,
+			want: []Problem{
+				{Line: 1, Column: 87, Message: "`nth` should not be negative, but given -1"},
+			},
+		},
+		"Empty replacer": {
+			input: `empty: '0.39.0' # selfup { "extract": "\\d[^']+", "replacer": [] }
+`,
+			want: []Problem{
+				{Line: 1, Column: 63, Message: "`replacer` is empty"},
+			},
+		},
+		"Invalid regex and missing executable": {
+			input: `Header
+broken: '0.1.0' # selfup { "extract": "[", "replacer": ["this_command_does_not_exist"] }
+`,
+			want: []Problem{
+				{Line: 2, Column: 39, Message: "Invalid regex `[`: error parsing regexp: missing closing ]: `[`"},
+				{Line: 2, Column: 56, Message: "Executable `this_command_does_not_exist` is not found in PATH"},
+			},
+		},
+		"Extract does not match the current line": {
+			input: `unmatched: 'latest' # selfup { "extract": "\\d[^']+", "replacer": ["echo", "0.76.9"] }
+`,
+			want: []Problem{
+				{Line: 1, Column: 1, Message: "`extract` does not match the current line: \\d[^']+"},
+			},
+		},
+		"Key without format": {
+			input: `v: '0.39.0' # selfup { "key": "v", "replacer": ["echo", "0.76.9"] }
+`,
+			want: []Problem{
+				{Line: 1, Column: 22, Message: "`extract` is missing"},
+				{Line: 1, Column: 31, Message: "`key` is available only in YAML, TOML and JSON files"},
+			},
+		},
+		"Key and implicit key": {
+			input: `version: '0.39.0' # selfup { "replacer": ["echo", "0.76.9"] }
+# selfup { "key": "version", "replacer": ["echo", "0.76.9"] }
+# selfup { "key": "jobs.missing", "replacer": ["echo", "0.76.9"] }
+jobs: # selfup { "replacer": ["echo", "0.76.9"] }
+`,
+			format: structured.YAML{},
+			want: []Problem{
+				{Line: 3, Column: 19, Message: "Key `jobs.missing` is not found or not a scalar"},
+				{Line: 4, Column: 16, Message: "No values are found for the implicit key in this line, specify `extract` or `key`"},
+			},
+		},
+		"SkipBy": {
+			input: `broken: ':<' # selfup {{ """" }
+`,
+			skipBy: "broken",
+			want:   []Problem{},
+		},
+	}
+
+	for what, tc := range testCases {
+		t.Run(what, func(t *testing.T) {
+			opts := Options{Prefix: regexp.MustCompile(defaultPrefix), SkipBy: tc.skipBy, Format: tc.format, AllowUnknownFields: tc.allowUnknownFields}
+			problems, err := ValidateWith(strings.NewReader(tc.input), opts)
+			if err != nil {
+				t.Fatalf("unexpected error happened: %v", err)
+			}
+
+			if diff := cmp.Diff(tc.want, problems); diff != "" {
+				t.Errorf("wrong result: %s", diff)
+			}
+		})
+	}
+
+	// Details of syntax errors depend on the Go version
+	t.Run("Broken JSON", func(t *testing.T) {
+		input := `Header
+broken: ':<' # selfup {{ """" }
+`
+		problems, err := Validate(strings.NewReader(input), regexp.MustCompile(defaultPrefix), nil, nil, "")
+		if err != nil {
+			t.Fatalf("unexpected error happened: %v", err)
+		}
+		if len(problems) != 1 || problems[0].Line != 2 || !strings.HasPrefix(problems[0].Message, "Invalid JSON: ") {
+			t.Errorf("wrong result: %v", problems)
+		}
+	})
+}
+
+func TestValidateRelativeExecutable(t *testing.T) {
+	root := t.TempDir()
+	err := os.Mkdir(filepath.Join(root, "scripts"), 0755)
+	if err != nil {
+		t.Fatalf("unexpected error happened: %v", err)
+	}
+	err = os.WriteFile(filepath.Join(root, "scripts", "version.sh"), []byte("#!/bin/sh\necho 0.76.9\n"), 0755)
+	if err != nil {
+		t.Fatalf("unexpected error happened: %v", err)
+	}
+
+	input := `found: '0.39.0' # selfup { "extract": "\\d[^']+", "replacer": ["./version.sh"], "cwd": "scripts" }
+missing: '0.39.0' # selfup { "extract": "\\d[^']+", "replacer": ["./version.sh"] }
+`
+	problems, err := ValidateWith(strings.NewReader(input), Options{Prefix: regexp.MustCompile(defaultPrefix), Dir: root, Root: root})
+	if err != nil {
+		t.Fatalf("unexpected error happened: %v", err)
+	}
+	want := []Problem{
+		{Line: 2, Column: 65, Message: "Executable `./version.sh` is not found in the working directory"},
+	}
+	if diff := cmp.Diff(want, problems); diff != "" {
+		t.Errorf("wrong result: %s", diff)
+	}
+}
@@ -0,0 +1,173 @@
+package schema
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+
+	"github.com/kachick/selfup/internal/runner"
+	"golang.org/x/xerrors"
+)
+
+// Version of the annotation format, bump this when changing runner.Definition except for adding optional fields.
+// Keep the golden files of the previous versions, and update migrate together if the old definitions become invalid.
+const Version = "v2"
+
+type Property struct {
+	Type                 string              `json:"type"`
+	Description          string              `json:"description,omitempty"`
+	Items                *Property           `json:"items,omitempty"`
+	Properties           map[string]Property `json:"properties,omitempty"`
+	AdditionalProperties any                 `json:"additionalProperties,omitempty"`
+	MinLength            *int                `json:"minLength,omitempty"`
+	MinItems             *int                `json:"minItems,omitempty"`
+	Minimum              *int                `json:"minimum,omitempty"`
+}
+
+type Schema struct {
+	Schema               string              `json:"$schema"`
+	Title                string              `json:"title"`
+	Type                 string              `json:"type"`
+	Properties           map[string]Property `json:"properties"`
+	Required             []string            `json:"required"`
+	AdditionalProperties bool                `json:"additionalProperties"`
+}
+
+func ptr(n int) *int {
+	return &n
+}
+
+// Every field in runner.Definition should be explicitly described here, nested fields are joined with "."
+var properties = map[string]Property{
+	"extract": {
+		Description: "Golang regex like RE2. Remember to escape meta-characters in JSON. It can be omitted in YAML, TOML and JSON files to update the value of the key in the annotated line.",
+		MinLength:   ptr(1),
+	},
+	"key": {
+		Description: "Key path of the updated value in YAML, TOML and JSON files, like \"jobs.lint.steps[1].with.version\". The extract narrows down the updated part in the value if given.",
+		MinLength:   ptr(1),
+	},
+	"replacer": {
+		Description: "Command and arguments. Use [\"bash\", \"-c\", \"your_script | as_using_pipe\"] for script style.",
+		MinItems:    ptr(1),
+	},
+	"nth": {
+		Description: "Field number. The first field is 1. By default, it uses the whole line (0).",
+		Minimum:     ptr(0),
+	},
+	"delimiter": {
+		Description: "Separator to split STDOUT into fields. It uses strings.Fields by default.",
+	},
+	"id": {
+		Description: "Optional name of the definition. It is used as the key in lock files instead of the hash of the replacer.",
+	},
+	"cwd": {
+		Description: "Working directory of the replacer. Relative paths are based on the annotated file, and paths starting with / are based on the repository root.",
+	},
+	"env": {
+		Description: "Environment variables of the replacer.",
+	},
+	"env.set": {
+		Description: "Variables to override.",
+	},
+	"env.unset": {
+		Description: "Variables to remove.",
+	},
+	"env.allow": {
+		Description: "Passes only these variables and the defaults like PATH from the environment.",
+	},
+}
+
+var required = []string{"replacer"}
+
+func jsonType(typ reflect.Type, path string, described map[string]bool) (Property, error) {
+	switch typ.Kind() {
+	case reflect.String:
+		return Property{Type: "string"}, nil
+	case reflect.Int:
+		return Property{Type: "integer"}, nil
+	case reflect.Slice:
+		items, err := jsonType(typ.Elem(), path, described)
+		if err != nil {
+			return Property{}, err
+		}
+		return Property{Type: "array", Items: &items}, nil
+	case reflect.Map:
+		values, err := jsonType(typ.Elem(), path, described)
+		if err != nil {
+			return Property{}, err
+		}
+		return Property{Type: "object", AdditionalProperties: values}, nil
+	case reflect.Pointer:
+		return jsonType(typ.Elem(), path, described)
+	case reflect.Struct:
+		object := Property{Type: "object", Properties: map[string]Property{}, AdditionalProperties: false}
+		for i := range typ.NumField() {
+			field := typ.Field(i)
+			name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+			fieldPath := name
+			if path != "" {
+				fieldPath = path + "." + name
+			}
+			property, ok := properties[fieldPath]
+			if !ok {
+				return Property{}, xerrors.Errorf("`%s` is not described in the schema %s", fieldPath, Version)
+			}
+			described[fieldPath] = true
+			generated, err := jsonType(field.Type, fieldPath, described)
+			if err != nil {
+				return Property{}, err
+			}
+			property.Type = generated.Type
+			property.Items = generated.Items
+			property.Properties = generated.Properties
+			property.AdditionalProperties = generated.AdditionalProperties
+			object.Properties[name] = property
+		}
+		return object, nil
+	default:
+		return Property{}, xerrors.Errorf("%s: Unsupported type `%s`", path, typ)
+	}
+}
+
+// Generate builds JSON Schema from runner.Definition
+func Generate() (Schema, error) {
+	described := map[string]bool{}
+	definition, err := jsonType(reflect.TypeFor[runner.Definition](), "", described)
+	if err != nil {
+		return Schema{}, err
+	}
+
+	for path := range properties {
+		if !described[path] {
+			return Schema{}, xerrors.Errorf("`%s` is described in the schema %s, but not found in the definition", path, Version)
+		}
+	}
+	for _, name := range required {
+		if _, ok := definition.Properties[name]; !ok {
+			return Schema{}, xerrors.Errorf("Required `%s` is not found in the definition", name)
+		}
+	}
+
+	return Schema{
+		Schema:               "https://json-schema.org/draft/2020-12/schema",
+		Title:                "selfup definition " + Version,
+		Type:                 "object",
+		Properties:           definition.Properties,
+		Required:             required,
+		AdditionalProperties: false,
+	}, nil
+}
+
+func JSON() ([]byte, error) {
+	schema, err := Generate()
+	if err != nil {
+		return nil, err
+	}
+	bytes, err := json.MarshalIndent(schema, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+
+	return append(bytes, '\n'), nil
+}
@@ -0,0 +1,75 @@
+package schema
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/kachick/selfup/internal/migrate"
+)
+
+func TestJSON(t *testing.T) {
+	got, err := JSON()
+	if err != nil {
+		t.Fatalf("unexpected error happened: %v", err)
+	}
+
+	// Make sure the schema changes are explicit in the diff
+	golden := filepath.Join("testdata", Version+".json")
+	want, err := os.ReadFile(golden)
+	if err != nil {
+		t.Fatalf("failed to read %s: %v", golden, err)
+	}
+
+	if diff := cmp.Diff(string(want), string(got)); diff != "" {
+		t.Errorf("wrong result, update %s with `selfup schema` if this is intended: %s", golden, diff)
+	}
+}
+
+// v2 only relaxed the required fields of v1, so v1 definitions need no migration
+func TestCompatibility(t *testing.T) {
+	bytes, err := os.ReadFile(filepath.Join("testdata", "v1.json"))
+	if err != nil {
+		t.Fatalf("unexpected error happened: %v", err)
+	}
+	v1 := Schema{}
+	err = json.Unmarshal(bytes, &v1)
+	if err != nil {
+		t.Fatalf("unexpected error happened: %v", err)
+	}
+	current, err := Generate()
+	if err != nil {
+		t.Fatalf("unexpected error happened: %v", err)
+	}
+
+	for name, property := range v1.Properties {
+		if got, ok := current.Properties[name]; !ok || got.Type != property.Type {
+			t.Errorf("`%s` in v1 should be kept in the schema %s", name, Version)
+		}
+	}
+	for _, name := range current.Required {
+		if !slices.Contains(v1.Required, name) {
+			t.Errorf("`%s` is newly required in the schema %s, migrate v1 definitions", name, Version)
+		}
+	}
+}
+
+func TestMigrationTarget(t *testing.T) {
+	schema, err := Generate()
+	if err != nil {
+		t.Fatalf("unexpected error happened: %v", err)
+	}
+
+	typ := reflect.TypeFor[migrate.V1Schema]()
+	for i := range typ.NumField() {
+		name, _, _ := strings.Cut(typ.Field(i).Tag.Get("json"), ",")
+		if _, ok := schema.Properties[name]; !ok {
+			t.Errorf("migrate.V1Schema has `%s`, but it is not found in the schema %s", name, Version)
+		}
+	}
+}
@@ -0,0 +1,178 @@
+package structured
+
+import (
+	"encoding/json"
+	"strings"
+
+	"golang.org/x/xerrors"
+)
+
+// JSON also accepts comments and trailing commas like in JSONC.
+type JSON struct{}
+
+type jsonScanner struct {
+	text string
+	pos  int
+	// Offsets of the line heads in the text
+	heads   []int
+	scalars []Scalar
+}
+
+func (s *jsonScanner) errorf(format string, a ...any) error {
+	line, _ := s.location(s.pos)
+	return xerrors.Errorf("Invalid JSON at line %d: %s", line+1, xerrors.Errorf(format, a...))
+}
+
+func (s *jsonScanner) location(offset int) (int, int) {
+	line := 0
+	for line+1 < len(s.heads) && s.heads[line+1] <= offset {
+		line++
+	}
+
+	return line, offset - s.heads[line]
+}
+
+func (s *jsonScanner) skip() {
+	for s.pos < len(s.text) {
+		rest := s.text[s.pos:]
+		switch {
+		case strings.ContainsRune(" \t\r\n", rune(rest[0])):
+			s.pos++
+		case strings.HasPrefix(rest, "//"):
+			end := strings.IndexByte(rest, '\n')
+			if end < 0 {
+				end = len(rest)
+			}
+			s.pos += end
+		case strings.HasPrefix(rest, "/*"):
+			end := strings.Index(rest, "*/")
+			if end < 0 {
+				s.pos = len(s.text)
+				return
+			}
+			s.pos += end + 2
+		default:
+			return
+		}
+	}
+}
+
+func (s *jsonScanner) peek() byte {
+	s.skip()
+	if s.pos >= len(s.text) {
+		return 0
+	}
+
+	return s.text[s.pos]
+}
+
+// Returns the offsets of the string content without the quotes
+func (s *jsonScanner) str() (int, int, error) {
+	end := closingQuote(s.text[s.pos:], '"')
+	if end < 0 || strings.ContainsRune(s.text[s.pos:s.pos+end], '\n') {
+		return 0, 0, s.errorf("unterminated string")
+	}
+	start := s.pos + 1
+	s.pos += end
+
+	return start, s.pos - 1, nil
+}
+
+func (s *jsonScanner) add(path []string, start int, end int, quote Quote) {
+	line, column := s.location(start)
+	s.scalars = append(s.scalars, Scalar{Path: joinPath(path), Line: line, Start: column, End: column + end - start, Quote: quote})
+}
+
+func (s *jsonScanner) value(path []string) error {
+	switch c := s.peek(); c {
+	case '{':
+		s.pos++
+		for s.peek() != '}' {
+			if s.peek() != '"' {
+				return s.errorf("expected a key")
+			}
+			start, end, err := s.str()
+			if err != nil {
+				return err
+			}
+			var key string
+			err = json.Unmarshal([]byte(s.text[start-1:end+1]), &key)
+			if err != nil {
+				return s.errorf("invalid key: %w", err)
+			}
+			if s.peek() != ':' {
+				return s.errorf("expected `:` after `%s`", key)
+			}
+			s.pos++
+			err = s.value(append(path, key))
+			if err != nil {
+				return err
+			}
+			if s.peek() != ',' {
+				break
+			}
+			s.pos++
+		}
+		if s.peek() != '}' {
+			return s.errorf("expected `}`")
+		}
+		s.pos++
+	case '[':
+		s.pos++
+		for i := 0; s.peek() != ']'; i++ {
+			err := s.value(append(path, index(i)))
+			if err != nil {
+				return err
+			}
+			if s.peek() != ',' {
+				break
+			}
+			s.pos++
+		}
+		if s.peek() != ']' {
+			return s.errorf("expected `]`")
+		}
+		s.pos++
+	case '"':
+		start, end, err := s.str()
+		if err != nil {
+			return err
+		}
+		s.add(path, start, end, Double)
+	case 0:
+		return s.errorf("unexpected end")
+	default:
+		start := s.pos
+		for s.pos < len(s.text) && !strings.ContainsRune(" \t\r\n,:]}/", rune(s.text[s.pos])) {
+			s.pos++
+		}
+		if s.pos == start {
+			return s.errorf("unexpected `%c`", c)
+		}
+		s.add(path, start, s.pos, Bare)
+	}
+
+	return nil
+}
+
+func (JSON) Scalars(lines []string) ([]Scalar, error) {
+	heads := make([]int, 0, len(lines))
+	offset := 0
+	for _, line := range lines {
+		heads = append(heads, offset)
+		offset += len(line) + 1
+	}
+	s := &jsonScanner{text: strings.Join(lines, "\n"), heads: heads, scalars: []Scalar{}}
+	if s.peek() == 0 {
+		return s.scalars, nil
+	}
+	err := s.value([]string{})
+	if err != nil {
+		return nil, err
+	}
+	if s.peek() != 0 {
+		return nil, s.errorf("unexpected trailing data")
+	}
+
+	return s.scalars, nil
+}
@@ -0,0 +1,36 @@
+package structured
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestJSONScalars(t *testing.T) {
+	input := `{
+  // selfup { "key": "plugins[0]", "extract": "\\d[^.]+\\.\\d+\\.\\d+", "replacer": ["echo", "0.19.1"] }
+  "plugins": ["https://plugins.dprint.dev/json-0.19.0.wasm", /* comment */ "x"],
+  "incremental": true,
+  "nested": { "escaped \"key\"": "a\"b", },
+}`
+	scalars, err := JSON{}.Scalars(strings.Split(input, "\n"))
+	if err != nil {
+		t.Fatalf("unexpected error happened: %v", err)
+	}
+
+	want := []Scalar{
+		{Path: "plugins[0]", Line: 2, Start: 15, End: 58, Quote: Double},
+		{Path: "plugins[1]", Line: 2, Start: 76, End: 77, Quote: Double},
+		{Path: "incremental", Line: 3, Start: 17, End: 21, Quote: Bare},
+		{Path: `nested.escaped "key"`, Line: 4, Start: 34, End: 38, Quote: Double},
+	}
+	if diff := cmp.Diff(want, scalars); diff != "" {
+		t.Errorf("wrong result: %s", diff)
+	}
+
+	_, err = JSON{}.Scalars([]string{`{ "a": 1`})
+	if err == nil {
+		t.Fatalf("expected error did not happen")
+	}
+}
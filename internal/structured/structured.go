@@ -0,0 +1,160 @@
+package structured
+
+import (
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"golang.org/x/xerrors"
+)
+
+// Quote is the style of a scalar, it is kept when updating the value
+type Quote int
+
+const (
+	// Unquoted scalar in YAML
+	Plain Quote = iota
+	// Unquoted number, boolean or date in TOML and JSON
+	Bare
+	// "..." with backslash escapes
+	Double
+	// '...' with '' escapes in YAML
+	Single
+	// '...' without escapes in TOML
+	Literal
+)
+
+// Scalar is a located scalar value in the lines
+type Scalar struct {
+	// Like "jobs.lint.steps[1].with.dprint-version"
+	Path string
+	// 0-based index of the line
+	Line int
+	// Byte offsets of the value in the line, quotes are excluded
+	Start int
+	End   int
+	Quote Quote
+}
+
+// Encode returns the value written in the same style of the scalar
+func (s Scalar) Encode(value string) (string, error) {
+	if strings.ContainsAny(value, "\r\n") {
+		return "", xerrors.Errorf("Multi-line value `%s` is not supported", value)
+	}
+
+	switch s.Quote {
+	case Double:
+		return strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(value), nil
+	case Single:
+		return strings.ReplaceAll(value, `'`, `''`), nil
+	case Literal:
+		if strings.Contains(value, `'`) {
+			return "", xerrors.Errorf("Value `%s` cannot be written in the literal string", value)
+		}
+		return value, nil
+	case Bare:
+		if !bareValue.MatchString(value) {
+			return "", xerrors.Errorf("Value `%s` cannot be written without quotes, quote the current value", value)
+		}
+		return value, nil
+	default:
+		if !isPlainYAML(value) {
+			return "", xerrors.Errorf("Value `%s` cannot be written as a plain scalar in YAML, quote the current value", value)
+		}
+		return value, nil
+	}
+}
+
+// Numbers, booleans and dates
+var bareValue = regexp.MustCompile(`^[0-9A-Za-z_.:+-]+$`)
+
+// Rejects values that change the meaning without quotes, like comments and mappings
+func isPlainYAML(value string) bool {
+	if value == "" || strings.TrimSpace(value) != value || strings.ContainsAny(value, "\t") {
+		return false
+	}
+	if strings.ContainsRune("[]{}#&*!|>'\"%@`,", rune(value[0])) {
+		return false
+	}
+	for _, indicator := range []string{"-", "?", ":"} {
+		if value == indicator || strings.HasPrefix(value, indicator+" ") {
+			return false
+		}
+	}
+
+	return !strings.Contains(value, ": ") && !strings.Contains(value, " #") && !strings.HasSuffix(value, ":")
+}
+
+// Format finds scalar values with the key paths, without fully parsing the document
+type Format interface {
+	Scalars(lines []string) ([]Scalar, error)
+}
+
+// ForPath returns the format of the file, or nil for unknown types
+func ForPath(path string) Format {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yml", ".yaml":
+		return YAML{}
+	case ".toml":
+		return TOML{}
+	case ".json", ".jsonc":
+		return JSON{}
+	default:
+		return nil
+	}
+}
+
+func index(i int) string {
+	return "[" + strconv.Itoa(i) + "]"
+}
+
+// Joins keys with "." and appends indexes like "[1]"
+func joinPath(segments []string) string {
+	var b strings.Builder
+	for i, s := range segments {
+		if i > 0 && !strings.HasPrefix(s, "[") {
+			b.WriteString(".")
+		}
+		b.WriteString(s)
+	}
+
+	return b.String()
+}
+
+// Returns the offset after the closing quote, or -1 if it is not closed in the string
+func closingQuote(s string, quote byte) int {
+	for i := 1; i < len(s); i++ {
+		switch {
+		case quote == '"' && s[i] == '\\':
+			i++
+		case quote == '\'' && s[i] == '\'' && i+1 < len(s) && s[i+1] == '\'':
+			i++
+		case s[i] == quote:
+			return i + 1
+		}
+	}
+
+	return -1
+}
+
+// Returns the quote style and the span of the value in s, or false if it is not a scalar
+func quotedSpan(s string, singleQuote Quote, unquoted Quote) (Quote, int, int, bool) {
+	if s == "" {
+		return unquoted, 0, 0, false
+	}
+	switch s[0] {
+	case '"', '\'':
+		end := closingQuote(s, s[0])
+		if end < 0 || strings.TrimSpace(s[end:]) != "" {
+			return unquoted, 0, 0, false
+		}
+		quote := Double
+		if s[0] == '\'' {
+			quote = singleQuote
+		}
+		return quote, 1, end - 1, true
+	default:
+		return unquoted, 0, len(s), true
+	}
+}
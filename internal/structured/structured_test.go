@@ -0,0 +1,66 @@
+package structured
+
+import (
+	"testing"
+)
+
+func TestEncode(t *testing.T) {
+	type testCase struct {
+		quote   Quote
+		value   string
+		want    string
+		wantErr bool
+	}
+
+	testCases := map[string]testCase{
+		"plain":         {quote: Plain, value: "0.40.2", want: "0.40.2"},
+		"plain URL":     {quote: Plain, value: "https://example.com/#v1", want: "https://example.com/#v1"},
+		"plain comment": {quote: Plain, value: "foo # bar", wantErr: true},
+		"plain mapping": {quote: Plain, value: "key: value", wantErr: true},
+		"plain quote":   {quote: Plain, value: `"quoted"`, wantErr: true},
+		"plain empty":   {quote: Plain, value: "", wantErr: true},
+		"bare":          {quote: Bare, value: "1.26", want: "1.26"},
+		"bare string":   {quote: Bare, value: "v1 beta", wantErr: true},
+		"double":        {quote: Double, value: `say "hi" \o/`, want: `say \"hi\" \\o/`},
+		"single":        {quote: Single, value: "it's", want: "it''s"},
+		"literal":       {quote: Literal, value: `C:\tools`, want: `C:\tools`},
+		"literal quote": {quote: Literal, value: "it's", wantErr: true},
+		"multi-line":    {quote: Double, value: "a\nb", wantErr: true},
+	}
+
+	for what, tc := range testCases {
+		t.Run(what, func(t *testing.T) {
+			got, err := Scalar{Quote: tc.quote}.Encode(tc.value)
+			if tc.wantErr {
+				if err == nil {
+					t.Fatalf("expected error did not happen")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error happened: %v", err)
+			}
+			if got != tc.want {
+				t.Errorf("wrong result: got %s, want %s", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestForPath(t *testing.T) {
+	testCases := map[string]Format{
+		".github/workflows/lint.yml": YAML{},
+		"Cargo.toml":                 TOML{},
+		"dprint.json":                JSON{},
+		".vscode/settings.jsonc":     JSON{},
+		"README.md":                  nil,
+	}
+
+	for path, want := range testCases {
+		t.Run(path, func(t *testing.T) {
+			if got := ForPath(path); got != want {
+				t.Errorf("wrong result: got %v, want %v", got, want)
+			}
+		})
+	}
+}
@@ -0,0 +1,134 @@
+package structured
+
+import (
+	"strings"
+
+	"github.com/kachick/selfup/internal/syntax"
+)
+
+// TOML handles tables, arrays of tables and dotted keys.
+// Inline tables, arrays and multi-line strings are not located.
+type TOML struct{}
+
+// Splits dotted keys like `a."b.c"` into the unquoted segments
+func tomlKeys(s string) []string {
+	keys := []string{}
+	for len(s) > 0 {
+		s = strings.TrimLeft(s, " \t")
+		end := strings.IndexByte(s, '.')
+		if s != "" && (s[0] == '"' || s[0] == '\'') {
+			end = closingQuote(s, s[0])
+			if end < 0 {
+				end = len(s)
+			}
+			keys = append(keys, strings.Trim(s[:end], `"'`))
+			s = strings.TrimLeft(s[end:], " \t")
+			s = strings.TrimPrefix(s, ".")
+			continue
+		}
+		if end < 0 {
+			end = len(s)
+		}
+		keys = append(keys, strings.TrimSpace(s[:end]))
+		s = s[min(end+1, len(s)):]
+	}
+
+	return keys
+}
+
+// Returns the offset of `=` outside quoted keys
+func tomlAssignment(s string) int {
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '"', '\'':
+			end := closingQuote(s[i:], s[i])
+			if end < 0 {
+				return -1
+			}
+			i += end - 1
+		case '=':
+			return i
+		}
+	}
+
+	return -1
+}
+
+func (TOML) Scalars(lines []string) ([]Scalar, error) {
+	comments := syntax.TOML.Comments(lines)
+	table := []string{}
+	// Count of the array of tables for each path
+	arrays := map[string]int{}
+	// Resolves keys of arrays of tables into their last elements
+	resolve := func(keys []string) []string {
+		resolved := []string{}
+		for _, key := range keys {
+			resolved = append(resolved, key)
+			if count := arrays[joinPath(resolved)]; count > 0 {
+				resolved = append(resolved, index(count-1))
+			}
+		}
+		return resolved
+	}
+	scalars := []Scalar{}
+	multiline := ""
+
+	for i, line := range lines {
+		end := len(line)
+		if len(comments[i]) > 0 {
+			end = comments[i][0].Start
+		}
+		content := strings.TrimRight(line[:end], " \t")
+		trimmed := strings.TrimLeft(content, " \t")
+		if multiline != "" {
+			if strings.Contains(line, multiline) {
+				multiline = ""
+			}
+			continue
+		}
+		switch {
+		case trimmed == "":
+			continue
+		case strings.HasPrefix(trimmed, "[["):
+			keys := tomlKeys(strings.TrimSuffix(strings.TrimPrefix(trimmed, "[["), "]]"))
+			resolved := resolve(keys[:len(keys)-1])
+			resolved = append(resolved, keys[len(keys)-1])
+			name := joinPath(resolved)
+			table = append(resolved, index(arrays[name]))
+			arrays[name]++
+			continue
+		case strings.HasPrefix(trimmed, "["):
+			table = resolve(tomlKeys(strings.TrimSuffix(strings.TrimPrefix(trimmed, "["), "]")))
+			continue
+		}
+
+		assignment := tomlAssignment(trimmed)
+		if assignment < 0 {
+			continue
+		}
+		rest := trimmed[assignment+1:]
+		value := strings.TrimLeft(rest, " \t")
+		offset := len(content) - len(trimmed) + assignment + 1 + len(rest) - len(value)
+		if value == "" {
+			continue
+		}
+		if strings.HasPrefix(value, `"""`) || strings.HasPrefix(value, `'''`) {
+			if !strings.Contains(value[3:], value[:3]) {
+				multiline = value[:3]
+			}
+			continue
+		}
+		if value[0] == '[' || value[0] == '{' {
+			continue
+		}
+
+		quote, start, end, ok := quotedSpan(value, Literal, Bare)
+		if !ok {
+			continue
+		}
+		keys := append(append([]string{}, table...), tomlKeys(trimmed[:assignment])...)
+		scalars = append(scalars, Scalar{Path: joinPath(keys), Line: i, Start: offset + start, End: offset + end, Quote: quote})
+	}
+
+	return scalars, nil
+}
@@ -0,0 +1,40 @@
+package structured
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestTOMLScalars(t *testing.T) {
+	input := `version = "0.39.0" # selfup { "replacer": ["dprint", "--version"], "nth": 2 }
+[tools]
+"go.version" = '1.26'
+node.version = 22
+description = """
+key = "not a key"
+"""
+[[plugins]]
+url = "https://plugins.dprint.dev/json-0.19.0.wasm"
+[[plugins]]
+url = "https://plugins.dprint.dev/toml-0.6.0.wasm"
+[plugins.options]
+lines = [1, 2]
+`
+	scalars, err := TOML{}.Scalars(strings.Split(input, "\n"))
+	if err != nil {
+		t.Fatalf("unexpected error happened: %v", err)
+	}
+
+	want := []Scalar{
+		{Path: "version", Line: 0, Start: 11, End: 17, Quote: Double},
+		{Path: "tools.go.version", Line: 2, Start: 16, End: 20, Quote: Literal},
+		{Path: "tools.node.version", Line: 3, Start: 15, End: 17, Quote: Bare},
+		{Path: "plugins[0].url", Line: 8, Start: 7, End: 50, Quote: Double},
+		{Path: "plugins[1].url", Line: 10, Start: 7, End: 49, Quote: Double},
+	}
+	if diff := cmp.Diff(want, scalars); diff != "" {
+		t.Errorf("wrong result: %s", diff)
+	}
+}
@@ -0,0 +1,144 @@
+package structured
+
+import (
+	"strings"
+
+	"github.com/kachick/selfup/internal/syntax"
+)
+
+// YAML handles block mappings and block sequences by the indentation.
+// Flow collections, anchors and multi-line scalars are not located.
+type YAML struct{}
+
+type yamlNode struct {
+	indent  int
+	segment string
+	isItem  bool
+	items   int
+}
+
+// Returns the key and the offset of the value for `key: value`, or false if s is not a mapping entry
+func yamlKey(s string) (string, int, bool) {
+	keyEnd := 0
+	if s[0] == '"' || s[0] == '\'' {
+		keyEnd = closingQuote(s, s[0])
+		if keyEnd < 0 {
+			return "", 0, false
+		}
+	}
+	colon := -1
+	for i := keyEnd; i < len(s); i++ {
+		if s[i] == ':' && (i+1 == len(s) || s[i+1] == ' ') {
+			colon = i
+			break
+		}
+	}
+	if colon < 0 {
+		return "", 0, false
+	}
+	key := strings.TrimSpace(s[:colon])
+	if keyEnd > 0 {
+		key = key[1 : len(key)-1]
+	}
+
+	return key, colon + 1, true
+}
+
+func (YAML) Scalars(lines []string) ([]Scalar, error) {
+	comments := syntax.YAML.Comments(lines)
+	root := &yamlNode{indent: -1}
+	stack := []*yamlNode{root}
+	pop := func(until func(n *yamlNode) bool) {
+		for len(stack) > 1 && until(stack[len(stack)-1]) {
+			stack = stack[:len(stack)-1]
+		}
+	}
+	path := func() string {
+		segments := []string{}
+		for _, n := range stack[1:] {
+			segments = append(segments, n.segment)
+		}
+		return joinPath(segments)
+	}
+	scalars := []Scalar{}
+	// Lines deeper than this are in a block scalar like `run: |`
+	blockIndent := -1
+
+lines:
+	for i, line := range lines {
+		end := len(line)
+		if len(comments[i]) > 0 {
+			end = comments[i][0].Start
+		}
+		content := strings.TrimRight(line[:end], " \t")
+		trimmed := strings.TrimLeft(content, " ")
+		if trimmed == "" {
+			continue
+		}
+		pos := len(content) - len(trimmed)
+		if blockIndent >= 0 {
+			if pos > blockIndent {
+				continue
+			}
+			blockIndent = -1
+		}
+		if pos == 0 && (trimmed == "---" || trimmed == "..." || strings.HasPrefix(trimmed, "--- ")) {
+			stack = []*yamlNode{root}
+			root.items = 0
+			continue
+		}
+
+		lineIndent := pos
+		isItem := false
+		for trimmed == "-" || strings.HasPrefix(trimmed, "- ") {
+			pop(func(n *yamlNode) bool {
+				return n.indent > pos || (n.indent == pos && n.isItem)
+			})
+			parent := stack[len(stack)-1]
+			stack = append(stack, &yamlNode{indent: pos, segment: index(parent.items), isItem: true})
+			parent.items++
+			lineIndent = pos
+			isItem = true
+			rest := trimmed[1:]
+			trimmed = strings.TrimLeft(rest, " ")
+			pos += 1 + len(rest) - len(trimmed)
+			if trimmed == "" {
+				continue lines
+			}
+		}
+
+		value := trimmed
+		offset := pos
+		if key, valueOffset, ok := yamlKey(trimmed); ok {
+			pop(func(n *yamlNode) bool {
+				return n.indent >= pos
+			})
+			stack = append(stack, &yamlNode{indent: pos, segment: key})
+			lineIndent = pos
+			rest := trimmed[valueOffset:]
+			value = strings.TrimLeft(rest, " ")
+			offset = pos + valueOffset + len(rest) - len(value)
+		} else if !isItem {
+			// Continuation of a multi-line scalar
+			continue
+		}
+		if value == "" {
+			continue
+		}
+		switch value[0] {
+		case '|', '>':
+			blockIndent = lineIndent
+			continue
+		case '[', '{', '&', '*', '!':
+			continue
+		}
+
+		quote, start, end, ok := quotedSpan(value, Single, Plain)
+		if !ok {
+			continue
+		}
+		scalars = append(scalars, Scalar{Path: path(), Line: i, Start: offset + start, End: offset + end, Quote: quote})
+	}
+
+	return scalars, nil
+}
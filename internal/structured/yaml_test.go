@@ -0,0 +1,49 @@
+package structured
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestYAMLScalars(t *testing.T) {
+	input := `name: Lint # comment
+on:
+  push:
+jobs:
+  dprint:
+    runs-on: "ubuntu-24.04"
+    steps:
+      - uses: actions/checkout@v4
+      - uses: dprint/check@v2.2
+        with:
+          dprint-version: '0.39.0' # selfup { "replacer": ["dprint", "--version"], "nth": 2 }
+      - run: |
+          echo key: value
+      - plain item
+  list:
+  - 'it''s'
+"quoted key": [1, 2]
+---
+next: 1.0.0
+`
+	scalars, err := YAML{}.Scalars(strings.Split(input, "\n"))
+	if err != nil {
+		t.Fatalf("unexpected error happened: %v", err)
+	}
+
+	want := []Scalar{
+		{Path: "name", Line: 0, Start: 6, End: 10},
+		{Path: "jobs.dprint.runs-on", Line: 5, Start: 14, End: 26, Quote: Double},
+		{Path: "jobs.dprint.steps[0].uses", Line: 7, Start: 14, End: 33},
+		{Path: "jobs.dprint.steps[1].uses", Line: 8, Start: 14, End: 31},
+		{Path: "jobs.dprint.steps[1].with.dprint-version", Line: 10, Start: 27, End: 33, Quote: Single},
+		{Path: "jobs.dprint.steps[3]", Line: 13, Start: 8, End: 18},
+		{Path: "jobs.list[0]", Line: 15, Start: 5, End: 10, Quote: Single},
+		{Path: "next", Line: 18, Start: 6, End: 11},
+	}
+	if diff := cmp.Diff(want, scalars); diff != "" {
+		t.Errorf("wrong result: %s", diff)
+	}
+}
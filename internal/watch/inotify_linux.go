@@ -0,0 +1,79 @@
+//go:build linux
+
+package watch
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"syscall"
+	"unsafe"
+
+	"golang.org/x/xerrors"
+)
+
+// Watches the directories because editors often replace the files with renaming
+const inotifyMask = syscall.IN_CLOSE_WRITE | syscall.IN_MOVED_TO | syscall.IN_CREATE
+
+func notify(ctx context.Context, paths []string) (<-chan string, error) {
+	fd, err := syscall.InotifyInit1(syscall.IN_CLOEXEC | syscall.IN_NONBLOCK)
+	if err != nil {
+		return nil, xerrors.Errorf("Initializing inotify has been failed: %w", err)
+	}
+	// Non-blocking fd is handled by the runtime poller, then Close interrupts Read
+	file := os.NewFile(uintptr(fd), "inotify")
+
+	dirs := map[int]string{}
+	for _, path := range paths {
+		dir, err := filepath.Abs(filepath.Dir(path))
+		if err != nil {
+			file.Close()
+			return nil, err
+		}
+		wd, err := syscall.InotifyAddWatch(fd, dir, inotifyMask)
+		if err != nil {
+			file.Close()
+			return nil, xerrors.Errorf("Watching %s has been failed: %w", dir, err)
+		}
+		dirs[wd] = dir
+	}
+
+	match := matcher(paths)
+	events := make(chan string)
+	go func() {
+		<-ctx.Done()
+		file.Close()
+	}()
+	go func() {
+		defer close(events)
+		buf := make([]byte, 64*(syscall.SizeofInotifyEvent+syscall.NAME_MAX+1))
+		for {
+			n, err := file.Read(buf)
+			if err != nil {
+				return
+			}
+			for offset := 0; offset+syscall.SizeofInotifyEvent <= n; {
+				event := (*syscall.InotifyEvent)(unsafe.Pointer(&buf[offset]))
+				nameBytes := buf[offset+syscall.SizeofInotifyEvent : offset+syscall.SizeofInotifyEvent+int(event.Len)]
+				offset += syscall.SizeofInotifyEvent + int(event.Len)
+
+				name := string(nameBytes)
+				// Names are padded with NUL
+				for len(name) > 0 && name[len(name)-1] == 0 {
+					name = name[:len(name)-1]
+				}
+				path := match(filepath.Join(dirs[int(event.Wd)], name))
+				if path == "" {
+					continue
+				}
+				select {
+				case events <- path:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+	}()
+
+	return events, nil
+}
@@ -0,0 +1,55 @@
+//go:build !linux
+
+package watch
+
+import (
+	"context"
+	"os"
+	"time"
+)
+
+const pollInterval = 500 * time.Millisecond
+
+func modTimes(paths []string) map[string]time.Time {
+	times := map[string]time.Time{}
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil {
+			continue
+		}
+		times[path] = info.ModTime()
+	}
+
+	return times
+}
+
+func notify(ctx context.Context, paths []string) (<-chan string, error) {
+	events := make(chan string)
+	go func() {
+		defer close(events)
+		last := modTimes(paths)
+		ticker := time.NewTicker(pollInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+			current := modTimes(paths)
+			for _, path := range paths {
+				if current[path].Equal(last[path]) {
+					continue
+				}
+				select {
+				case events <- path:
+				case <-ctx.Done():
+					return
+				}
+			}
+			last = current
+		}
+	}()
+
+	return events, nil
+}
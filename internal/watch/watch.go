@@ -0,0 +1,125 @@
+package watch
+
+import (
+	"context"
+	"encoding/json"
+	"path/filepath"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/kachick/selfup/internal/runner"
+)
+
+// DefaultDebounce waits for editors that write a file in multiple steps
+const DefaultDebounce = 100 * time.Millisecond
+
+// Watch calls fn with the modified paths after they settle down for the debounce duration, until ctx is done.
+// It uses inotify on Linux, and polls the modification times on other platforms.
+func Watch(ctx context.Context, paths []string, debounce time.Duration, fn func(modified []string)) error {
+	events, err := notify(ctx, paths)
+	if err != nil {
+		return err
+	}
+
+	debounced(ctx, events, debounce, fn)
+
+	return nil
+}
+
+// Collects the events until no events happen in the duration, and passes the sorted paths
+func debounced(ctx context.Context, events <-chan string, duration time.Duration, fn func(modified []string)) {
+	pending := map[string]bool{}
+	timer := time.NewTimer(duration)
+	timer.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case path, ok := <-events:
+			if !ok {
+				return
+			}
+			pending[path] = true
+			timer.Reset(duration)
+		case <-timer.C:
+			modified := []string{}
+			for path := range pending {
+				modified = append(modified, path)
+			}
+			slices.Sort(modified)
+			clear(pending)
+			fn(modified)
+		}
+	}
+}
+
+// Returns the watched path for the notified path, or empty if it is not watched
+func matcher(paths []string) func(notified string) string {
+	watched := map[string]string{}
+	for _, path := range paths {
+		abs, err := filepath.Abs(path)
+		if err != nil {
+			continue
+		}
+		watched[abs] = path
+	}
+
+	return func(notified string) string {
+		abs, err := filepath.Abs(notified)
+		if err != nil {
+			return ""
+		}
+		return watched[abs]
+	}
+}
+
+// CachingResolver reuses the resolved values between iterations, so unchanged replacers are not executed again
+type CachingResolver struct {
+	Next runner.Resolver
+
+	mu     sync.Mutex
+	values map[string]string
+}
+
+// Definitions are edited while watching, so the ID is not used and all resolving fields are compared
+func cacheKey(def runner.Definition, cmd runner.Command) string {
+	key, _ := json.Marshal(struct {
+		Command   []string
+		Nth       int
+		Delimiter string
+		Cwd       string
+		Env       *runner.Env
+		Argv      []string
+		Dir       string
+		CmdEnv    []string
+	}{def.Command, def.Nth, def.Delimiter, def.Cwd, def.Env, cmd.Argv, cmd.Dir, cmd.Env})
+
+	return string(key)
+}
+
+func (r *CachingResolver) Resolve(def runner.Definition, cmd runner.Command) (string, error) {
+	key := cacheKey(def, cmd)
+
+	r.mu.Lock()
+	value, ok := r.values[key]
+	r.mu.Unlock()
+	if ok {
+		return value, nil
+	}
+
+	value, err := r.Next.Resolve(def, cmd)
+	if err != nil {
+		return "", err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.values == nil {
+		r.values = map[string]string{}
+	}
+	r.values[key] = value
+
+	return value, nil
+}
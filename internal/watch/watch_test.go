@@ -0,0 +1,129 @@
+package watch
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/kachick/selfup/internal/runner"
+)
+
+func TestDebounced(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	events := make(chan string)
+	calls := make(chan []string, 10)
+	go debounced(ctx, events, 50*time.Millisecond, func(modified []string) {
+		calls <- modified
+	})
+
+	for _, path := range []string{"b.yml", "a.yml", "b.yml"} {
+		events <- path
+	}
+
+	select {
+	case got := <-calls:
+		if diff := cmp.Diff([]string{"a.yml", "b.yml"}, got); diff != "" {
+			t.Errorf("wrong result: %s", diff)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("debounced events are not passed")
+	}
+	select {
+	case got := <-calls:
+		t.Errorf("expected events are merged into one call, but got another: %v", got)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+func TestWatch(t *testing.T) {
+	dir := t.TempDir()
+	watched := filepath.Join(dir, "watched.yml")
+	unwatched := filepath.Join(dir, "unwatched.yml")
+	for _, path := range []string{watched, unwatched} {
+		err := os.WriteFile(path, []byte("version: 0.39.0\n"), 0o644)
+		if err != nil {
+			t.Fatalf("unexpected error happened: %v", err)
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	calls := make(chan []string, 10)
+	done := make(chan error)
+	go func() {
+		done <- Watch(ctx, []string{watched}, 10*time.Millisecond, func(modified []string) {
+			calls <- modified
+		})
+	}()
+
+	// Waits for the polling fallback to take the first snapshot
+	time.Sleep(time.Second)
+	for _, path := range []string{unwatched, watched} {
+		err := os.WriteFile(path, []byte("version: 0.40.2\n"), 0o644)
+		if err != nil {
+			t.Fatalf("unexpected error happened: %v", err)
+		}
+	}
+
+	select {
+	case got := <-calls:
+		if diff := cmp.Diff([]string{watched}, got); diff != "" {
+			t.Errorf("wrong result: %s", diff)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("modification is not notified")
+	}
+
+	cancel()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("unexpected error happened: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("watching does not stop")
+	}
+}
+
+func TestCachingResolver(t *testing.T) {
+	executed := 0
+	resolver := &CachingResolver{Next: runner.CommandResolver{Executor: runner.ExecutorFunc(func(cmd runner.Command) ([]byte, error) {
+		executed++
+		return []byte("0.40.2\n"), nil
+	})}}
+
+	def := runner.Definition{Command: []string{"dprint", "--version"}}
+	for _, dir := range []string{"a", "a", "b"} {
+		value, err := resolver.Resolve(def, runner.Command{Argv: def.Command, Dir: dir})
+		if err != nil {
+			t.Fatalf("unexpected error happened: %v", err)
+		}
+		if value != "0.40.2" {
+			t.Errorf("wrong value: %s", value)
+		}
+	}
+	if executed != 2 {
+		t.Errorf("expected the command is executed once for each directory, but executed %d times", executed)
+	}
+
+	// Edited definitions with the same ID should be resolved again, and adding the ID does not change the resolution
+	for _, edited := range []runner.Definition{
+		{ID: "dprint", Command: []string{"dprint", "--version"}},
+		{ID: "dprint", Command: []string{"dprint", "--version"}, Nth: 1},
+		{ID: "dprint", Command: []string{"dprint", "--version"}, Nth: 1, Delimiter: "."},
+		{ID: "dprint", Command: []string{"dprint", "-V"}, Nth: 1, Delimiter: "."},
+		{ID: "dprint", Command: []string{"dprint", "-V"}, Nth: 1, Delimiter: ".", Env: &runner.Env{Set: map[string]string{"NO_COLOR": "1"}}},
+	} {
+		_, err := resolver.Resolve(edited, runner.Command{Argv: edited.Command, Dir: "a"})
+		if err != nil {
+			t.Fatalf("unexpected error happened: %v", err)
+		}
+	}
+	if executed != 6 {
+		t.Errorf("expected the edited definitions are resolved again, but executed %d times", executed)
+	}
+}
@@ -22,6 +22,7 @@ buildGo126Module (finalAttrs: {
       ./go.sum
       ./cmd
       ./internal
+      ./selfup
     ];
   };
   # src = lib.cleanSource self; # Requires this old style if I use nix-update
@@ -0,0 +1,9 @@
+// Package selfup replaces strings in files using update rules defined in comments.
+//
+// This is the library API of the selfup command. Plan reports how the given content will be updated,
+// and Apply writes the updated content.
+//
+// The exported identifiers in this package follow semantic versioning of the selfup module.
+// They will not have breaking changes except in major versions.
+// Everything under internal/ is not covered by this guarantee.
+package selfup
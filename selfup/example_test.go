@@ -0,0 +1,25 @@
+package selfup_test
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/kachick/selfup/selfup"
+)
+
+func ExampleApply() {
+	input := `dprint-version: '0.39.0' # selfup { "extract": "\\d[^']+", "replacer": ["dprint", "--version"], "nth": 2 }`
+
+	executor := selfup.ExecutorFunc(func(cmd selfup.Command) ([]byte, error) {
+		return []byte("dprint 0.40.2\n"), nil
+	})
+	result, err := selfup.Apply(strings.NewReader(input), os.Stdout, selfup.Options{Executor: executor})
+	if err != nil {
+		panic(err)
+	}
+	fmt.Printf("%d/%d items have been replaced\n", result.ChangedCount, result.Total)
+	// Output:
+	// dprint-version: '0.40.2' # selfup { "extract": "\\d[^']+", "replacer": ["dprint", "--version"], "nth": 2 }
+	// 1/1 items have been replaced
+}
@@ -0,0 +1,322 @@
+package selfup
+
+import (
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/kachick/selfup/internal/runner"
+	"github.com/kachick/selfup/internal/structured"
+	"golang.org/x/xerrors"
+)
+
+// DefaultPrefix is the pattern before JSON that is used in the selfup command by default
+const DefaultPrefix = runner.DefaultPrefix
+
+// Definition is the JSON in annotations
+type Definition struct {
+	Extract   string
+	KeyPath   string
+	Command   []string
+	Nth       int
+	Delimiter string
+	ID        string
+	Cwd       string
+	Env       *Env
+}
+
+// Env modifies the environment variables for the replacer
+type Env struct {
+	Set   map[string]string
+	Unset []string
+	Allow []string
+}
+
+// Span is a half-open range of 0-based offsets in the line
+type Span struct {
+	Start int
+	End   int
+}
+
+// Target is a line that has a definition
+type Target struct {
+	// Line of the value, it differs from AnnotationLineNumber when the definition has `key`
+	LineNumber           int
+	AnnotationLineNumber int
+	Extracted            string
+	Replacer             string
+	IsChanged            bool
+	ID                   string
+	Command              []string
+	// Positions in the original lines, in bytes and in characters(runes)
+	ValueBytes      Span
+	ValueRunes      Span
+	AnnotationBytes Span
+	AnnotationRunes Span
+}
+
+// Skipped is a definition skipped by a directive like `# selfup-ignore` in the content
+type Skipped struct {
+	LineNumber int
+	Directive  string
+}
+
+type Result struct {
+	NewLines     []string
+	Targets      []Target
+	ChangedCount int
+	// Skipped definitions are not counted
+	Total   int
+	Skipped []Skipped
+}
+
+// Resolver returns the string that should replace the extracted string.
+// It takes priority over Executor.
+type Resolver interface {
+	Resolve(def Definition) (string, error)
+}
+
+// ResolverFunc is an adapter to use ordinary functions as Resolver
+type ResolverFunc func(def Definition) (string, error)
+
+func (f ResolverFunc) Resolve(def Definition) (string, error) {
+	return f(def)
+}
+
+// CommandError is returned when the replacer command has been failed.
+// It has the argv, exit code, duration and the tail of STDERR.
+// Take it from the error of Plan or Apply with errors.As.
+type CommandError struct {
+	Argv []string
+	// -1 if the command did not exit, e.g. not found
+	ExitCode int
+	Duration time.Duration
+	// Truncated to the last 2048 bytes
+	Stderr string
+	Err    error
+}
+
+func (e *CommandError) Error() string {
+	return fmt.Sprintf("`%s` exited with %d after %s: %v", strings.Join(e.Argv, " "), e.ExitCode, e.Duration.Round(time.Millisecond), e.Err)
+}
+
+func (e *CommandError) Unwrap() error {
+	return e.Err
+}
+
+// commandFailure keeps the message of the error and provides the CommandError for errors.As
+type commandFailure struct {
+	err     error
+	command *CommandError
+}
+
+func (e *commandFailure) Error() string {
+	return e.err.Error()
+}
+
+func (e *commandFailure) Unwrap() error {
+	return e.err
+}
+
+func (e *commandFailure) As(target any) bool {
+	command, ok := target.(**CommandError)
+	if ok {
+		*command = e.command
+	}
+
+	return ok
+}
+
+// Converts the internal CommandError in the error chain
+func convertError(err error) error {
+	var cmdErr *runner.CommandError
+	if !xerrors.As(err, &cmdErr) {
+		return err
+	}
+
+	return &commandFailure{
+		err: err,
+		command: &CommandError{
+			Argv:     cmdErr.Argv,
+			ExitCode: cmdErr.ExitCode,
+			Duration: cmdErr.Duration,
+			Stderr:   cmdErr.Stderr,
+			Err:      cmdErr.Err,
+		},
+	}
+}
+
+// Command is what the Executor runs for a replacer
+type Command struct {
+	Argv []string
+	// Inherits the working directory of the process if empty
+	Dir string
+	// Inherits the environment of the process if nil, the format is same as os.Environ
+	Env []string
+}
+
+// Executor runs the replacer command and returns the STDOUT.
+// The nth and delimiter in definitions are applied to the STDOUT.
+type Executor interface {
+	Execute(cmd Command) ([]byte, error)
+}
+
+// ExecutorFunc is an adapter to use ordinary functions as Executor
+type ExecutorFunc func(cmd Command) ([]byte, error)
+
+func (f ExecutorFunc) Execute(cmd Command) ([]byte, error) {
+	return f(cmd)
+}
+
+type Options struct {
+	// Defaults to DefaultPrefix
+	Prefix *regexp.Regexp
+	// Optional pattern after the JSON like ` -->` in HTML comments
+	Suffix *regexp.Regexp
+	// Skips lines that contain this string
+	SkipBy string
+	// Defaults to executing the replacer commands with Executor
+	Resolver Resolver
+	// Defaults to os/exec
+	Executor Executor
+	// Ignores unknown fields in definitions instead of raising errors
+	AllowUnknownFields bool
+	// Directory of the content, relative "cwd" in definitions are based on this
+	Dir string
+	// Repository root, "cwd" starting with "/" in definitions are based on this
+	Root string
+	// Passes only the allowed environment variables to replacers
+	CleanEnv bool
+	// "yaml", "toml" or "json" to update values with `key` in definitions, or empty for other content
+	Format string
+}
+
+type resolverAdapter struct {
+	resolver Resolver
+}
+
+func (a resolverAdapter) Resolve(def runner.Definition, _ runner.Command) (string, error) {
+	converted := Definition{
+		Extract:   def.Extract,
+		KeyPath:   def.KeyPath,
+		Command:   def.Command,
+		Nth:       def.Nth,
+		Delimiter: def.Delimiter,
+		ID:        def.ID,
+		Cwd:       def.Cwd,
+	}
+	if def.Env != nil {
+		converted.Env = &Env{
+			Set:   def.Env.Set,
+			Unset: def.Env.Unset,
+			Allow: def.Env.Allow,
+		}
+	}
+
+	return a.resolver.Resolve(converted)
+}
+
+type executorAdapter struct {
+	executor Executor
+}
+
+func (a executorAdapter) Execute(cmd runner.Command) ([]byte, error) {
+	return a.executor.Execute(Command{Argv: cmd.Argv, Dir: cmd.Dir, Env: cmd.Env})
+}
+
+func (opts Options) runnerOptions() (runner.Options, error) {
+	var format structured.Format
+	switch opts.Format {
+	case "":
+	case "yaml":
+		format = structured.YAML{}
+	case "toml":
+		format = structured.TOML{}
+	case "json":
+		format = structured.JSON{}
+	default:
+		return runner.Options{}, xerrors.Errorf("Unknown format `%s`, it should be yaml, toml or json", opts.Format)
+	}
+	prefix := opts.Prefix
+	if prefix == nil {
+		prefix = regexp.MustCompile(DefaultPrefix)
+	}
+	runnerOpts := runner.Options{
+		Prefix:             prefix,
+		Suffix:             opts.Suffix,
+		SkipBy:             opts.SkipBy,
+		AllowUnknownFields: opts.AllowUnknownFields,
+		Dir:                opts.Dir,
+		Root:               opts.Root,
+		CleanEnv:           opts.CleanEnv,
+		Format:             format,
+	}
+	if opts.Executor != nil {
+		runnerOpts.Executor = executorAdapter{opts.Executor}
+	}
+	if opts.Resolver != nil {
+		runnerOpts.Resolver = resolverAdapter{opts.Resolver}
+	}
+
+	return runnerOpts, nil
+}
+
+// Plan reads the content and returns how it will be updated without writing anything
+func Plan(r io.Reader, opts Options) (Result, error) {
+	runnerOpts, err := opts.runnerOptions()
+	if err != nil {
+		return Result{}, err
+	}
+	result, err := runner.DryRunWith(r, runnerOpts)
+	if err != nil {
+		return Result{}, convertError(err)
+	}
+
+	targets := make([]Target, 0, len(result.Targets))
+	for _, t := range result.Targets {
+		targets = append(targets, Target{
+			LineNumber:           t.LineNumber,
+			AnnotationLineNumber: t.AnnotationLineNumber,
+			Extracted:            t.Extracted,
+			Replacer:             t.Replacer,
+			IsChanged:            t.IsChanged,
+			ID:                   t.ID,
+			Command:              t.Command,
+			ValueBytes:           Span(t.ValueBytes),
+			ValueRunes:           Span(t.ValueRunes),
+			AnnotationBytes:      Span(t.AnnotationBytes),
+			AnnotationRunes:      Span(t.AnnotationRunes),
+		})
+	}
+
+	var skipped []Skipped
+	for _, s := range result.Skipped {
+		skipped = append(skipped, Skipped(s))
+	}
+
+	return Result{
+		NewLines:     result.NewLines,
+		Targets:      targets,
+		ChangedCount: result.ChangedCount,
+		Total:        result.Total,
+		Skipped:      skipped,
+	}, nil
+}
+
+// Apply reads the content and writes the updated content into w.
+// Nothing is written if an error happened.
+func Apply(r io.Reader, w io.Writer, opts Options) (Result, error) {
+	result, err := Plan(r, opts)
+	if err != nil {
+		return Result{}, err
+	}
+
+	_, err = io.WriteString(w, strings.Join(result.NewLines, "\n")+"\n")
+	if err != nil {
+		return Result{}, err
+	}
+
+	return result, nil
+}
@@ -0,0 +1,144 @@
+package selfup
+
+import (
+	"errors"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestPlan(t *testing.T) {
+	input := `Header
+will_be_replaced: '0.39.0' # selfup { "extract": "\\d[^']+", "replacer": ["supertool", "--version"], "nth": 2 }
+not_be_replaced: '0.39.0' # selfup { "extract": "\\d[^']+", "replacer": ["othertool", "--version"] }
+`
+	executor := ExecutorFunc(func(cmd Command) ([]byte, error) {
+		if cmd.Argv[0] == "supertool" {
+			return []byte("supertool 0.76.9\n"), nil
+		}
+		return []byte("0.39.0\n"), nil
+	})
+
+	result, err := Plan(strings.NewReader(input), Options{Executor: executor})
+	if err != nil {
+		t.Fatalf("unexpected error happened: %v", err)
+	}
+
+	want := Result{
+		NewLines: []string{
+			`Header`,
+			`will_be_replaced: '0.76.9' # selfup { "extract": "\\d[^']+", "replacer": ["supertool", "--version"], "nth": 2 }`,
+			`not_be_replaced: '0.39.0' # selfup { "extract": "\\d[^']+", "replacer": ["othertool", "--version"] }`,
+		},
+		Targets: []Target{
+			{
+				LineNumber: 2, AnnotationLineNumber: 2, Extracted: "0.39.0", Replacer: "0.76.9", IsChanged: true, Command: []string{"supertool", "--version"},
+				ValueBytes: Span{Start: 19, End: 25}, ValueRunes: Span{Start: 19, End: 25},
+				AnnotationBytes: Span{Start: 36, End: 111}, AnnotationRunes: Span{Start: 36, End: 111},
+			},
+			{
+				LineNumber: 3, AnnotationLineNumber: 3, Extracted: "0.39.0", Replacer: "0.39.0", Command: []string{"othertool", "--version"},
+				ValueBytes: Span{Start: 18, End: 24}, ValueRunes: Span{Start: 18, End: 24},
+				AnnotationBytes: Span{Start: 35, End: 100}, AnnotationRunes: Span{Start: 35, End: 100},
+			},
+		},
+		ChangedCount: 1,
+		Total:        2,
+	}
+	if diff := cmp.Diff(want, result); diff != "" {
+		t.Errorf("wrong result: %s", diff)
+	}
+}
+
+func TestApply(t *testing.T) {
+	input := `version: 0.39.0 // Update { "id": "supertool", "extract": "[0-9.]+", "replacer": ["this_command_is_not_executed"] }
+`
+	resolver := ResolverFunc(func(def Definition) (string, error) {
+		if def.ID != "supertool" {
+			t.Fatalf("unexpected definition: %v", def)
+		}
+		return "0.76.9", nil
+	})
+
+	out := new(strings.Builder)
+	result, err := Apply(strings.NewReader(input), out, Options{Prefix: regexp.MustCompile(`\s*// Update `), Resolver: resolver})
+	if err != nil {
+		t.Fatalf("unexpected error happened: %v", err)
+	}
+	if result.ChangedCount != 1 {
+		t.Errorf("expected 1 change, got %d", result.ChangedCount)
+	}
+
+	want := `version: 0.76.9 // Update { "id": "supertool", "extract": "[0-9.]+", "replacer": ["this_command_is_not_executed"] }
+`
+	if diff := cmp.Diff(want, out.String()); diff != "" {
+		t.Errorf("wrong result: %s", diff)
+	}
+}
+
+func TestApplyDoesNotWriteOnError(t *testing.T) {
+	input := `broken: '0.1.0' # selfup { "extract": "[", "replacer": ["echo", "0.2.0"] }
+`
+	out := new(strings.Builder)
+	_, err := Apply(strings.NewReader(input), out, Options{})
+	if err == nil {
+		t.Fatalf("expected error did not happen")
+	}
+	if out.Len() != 0 {
+		t.Errorf("expected nothing is written, got %s", out.String())
+	}
+}
+
+func TestPlanCommandError(t *testing.T) {
+	input := `failed: '0.1.0' # selfup { "extract": "[\\d.]+", "replacer": ["sh", "-c", "echo 'supertool: not found' >&2; exit 3"] }
+`
+	_, err := Plan(strings.NewReader(input), Options{})
+	var cmdErr *CommandError
+	if !errors.As(err, &cmdErr) {
+		t.Fatalf("expected CommandError, got %v", err)
+	}
+	if cmdErr.ExitCode != 3 || cmdErr.Stderr != "supertool: not found\n" {
+		t.Errorf("wrong command error: %v", cmdErr)
+	}
+	if !strings.Contains(err.Error(), "1:") {
+		t.Errorf("the line should be kept in the message: %v", err)
+	}
+}
+
+func TestApplyFormat(t *testing.T) {
+	input := `# selfup { "key": "tools.dprint", "replacer": ["this_command_is_not_executed"] }
+[tools]
+dprint = "0.39.0" # keeps comments
+`
+	resolver := ResolverFunc(func(def Definition) (string, error) {
+		if def.KeyPath != "tools.dprint" {
+			t.Fatalf("unexpected definition: %v", def)
+		}
+		return "0.76.9", nil
+	})
+
+	out := new(strings.Builder)
+	_, err := Apply(strings.NewReader(input), out, Options{Resolver: resolver, Format: "toml"})
+	if err != nil {
+		t.Fatalf("unexpected error happened: %v", err)
+	}
+
+	want := `# selfup { "key": "tools.dprint", "replacer": ["this_command_is_not_executed"] }
+[tools]
+dprint = "0.76.9" # keeps comments
+`
+	if diff := cmp.Diff(want, out.String()); diff != "" {
+		t.Errorf("wrong result: %s", diff)
+	}
+}
+
+func TestPlanUnknownFormat(t *testing.T) {
+	for _, format := range []string{"xml", "yml", "TOML"} {
+		_, err := Plan(strings.NewReader("version: 1\n"), Options{Format: format})
+		if err == nil || !strings.Contains(err.Error(), "Unknown format") {
+			t.Errorf("expected an error for %s, got %v", format, err)
+		}
+	}
+}